@@ -0,0 +1,239 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+
+	"github.com/brian-l-johnson/Redteam-Dashboard-go/v2/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ArtifactController struct{}
+
+// findArtifact loads the artifact named in the path, writing an error
+// response if there is none
+func findArtifact(c *gin.Context) (models.Artifact, bool) {
+	var artifact models.Artifact
+	if err := models.GetDB().First(&artifact, "a_id = ?", c.Param("aid")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"status": "error", "message": "artifact not found"})
+			return artifact, false
+		}
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return artifact, false
+	}
+	return artifact, true
+}
+
+// GetArtifacts godoc
+// @Summary Get job artifacts
+// @Description List the raw files kept with a job: its results uploads and any attachments
+// @Tags artifacts
+// @Accept json
+// @Produce json
+// @Param jid query string true "Job ID"
+// @Success 200 {array} models.Artifact
+// @Router /artifacts [get]
+func (a ArtifactController) GetArtifacts(c *gin.Context) {
+	jid := c.Query("jid")
+	if jid == "" {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "jid is required"})
+		return
+	}
+
+	var artifacts []models.Artifact
+	result := models.GetDB().Where("j_id = ?", jid).Order("id ASC").Find(&artifacts)
+	if result.Error != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, artifacts)
+}
+
+// UploadArtifacts godoc
+// @Summary Attach files to a job
+// @Description Scanners can attach extra files to a job, such as nmap's normal output or a screenshot.
+// @Description Send them as multipart/form-data in one or more "file" fields. Set format to a scan format if the files can be re-ingested.
+// @Description Files are only accepted while the job is running, from the scanner holding it: attach them before uploading the results.
+// @Tags artifacts
+// @Accept mpfd
+// @Produce json
+// @Param jid path string true "Job ID"
+// @Param file formData file true "File to attach"
+// @Param format query string false "Scan format of the files"
+// @Success 201 {array} models.Artifact
+// @Router /jobs/{jid}/artifacts [post]
+func (a ArtifactController) UploadArtifacts(c *gin.Context) {
+	db := models.GetDB()
+	var job models.Job
+	if err := db.First(&job, "j_id = ?", c.Param("jid")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"status": "error", "message": "job not found"})
+			return
+		}
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	if !holdsJob(c, job, scannerName(c)) {
+		return
+	}
+	if job.Status != "running" {
+		c.IndentedJSON(http.StatusConflict, gin.H{"status": "error", "message": "job is " + job.Status + ", files not accepted"})
+		return
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["file"]) == 0 {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "expected multipart form with one or more file fields"})
+		return
+	}
+
+	artifacts := []models.Artifact{}
+	for _, header := range form.File["file"] {
+		file, err := header.Open()
+		if err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "unable to read " + header.Filename})
+			return
+		}
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "unable to read " + header.Filename})
+			return
+		}
+
+		artifact := models.MakeArtifact(job.JID, models.ArtifactAttachment, filepath.Base(header.Filename), data)
+		artifact.Format = c.Query("format")
+		artifact.ContentType = header.Header.Get("Content-Type")
+		artifact.UploadedBy = scannerName(c)
+		if err := models.StoreArtifact(&artifact, data); err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+			return
+		}
+		artifacts = append(artifacts, artifact)
+	}
+
+	c.IndentedJSON(http.StatusCreated, artifacts)
+}
+
+// DownloadArtifact godoc
+// @Summary Download a job artifact
+// @Description Download the raw content of a job artifact
+// @Tags artifacts
+// @Produce octet-stream
+// @Param aid path string true "Artifact ID"
+// @Success 200 {file} file
+// @Router /artifacts/{aid} [get]
+func (a ArtifactController) DownloadArtifact(c *gin.Context) {
+	artifact, ok := findArtifact(c)
+	if !ok {
+		return
+	}
+
+	data, err := artifact.Read()
+	if err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "artifact content is missing from the store"})
+		return
+	}
+
+	// The stored content type came from the scanner, so it is not trusted
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", artifact.JID[:8]+"-"+artifact.Name))
+	c.Header("ETag", `"`+artifact.SHA256+`"`)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, "application/octet-stream", data)
+}
+
+// ReingestArtifact godoc
+// @Summary Re-ingest a job artifact
+// @Description Parse a stored artifact again and merge it into the job's team, as if the scanner had just uploaded it.
+// @Description Use this after a parser fix. Only finished jobs can be re-ingested. The job's counts are updated; its status
+// @Description and scan history are not. If a later job of the same type has completed over any part of the same range, the
+// @Description artifact's ports are only added: no ports are closed and no hosts marked offline.
+// @Tags artifacts
+// @Produce json
+// @Param aid path string true "Artifact ID"
+// @Param format query string false "Scan format, if the artifact has none or it was wrong"
+// @Success 200 {object} map[string]interface{}
+// @Router /artifacts/{aid}/reingest [post]
+func (a ArtifactController) ReingestArtifact(c *gin.Context) {
+	artifact, ok := findArtifact(c)
+	if !ok {
+		return
+	}
+
+	format := c.DefaultQuery("format", artifact.Format)
+	if format == "" {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "artifact has no scan format, set the format parameter"})
+		return
+	}
+
+	db := models.GetDB()
+	var job models.Job
+	if err := db.First(&job, "j_id = ?", artifact.JID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"status": "error", "message": "job not found"})
+			return
+		}
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if job.Status == "queued" || job.Status == "running" {
+		c.IndentedJSON(http.StatusConflict, gin.H{"status": "error", "message": "job is " + job.Status + ", only finished jobs can be re-ingested"})
+		return
+	}
+
+	// Once a later job has scanned any of the same addresses, this one no
+	// longer says what is closed or offline there
+	stale, err := laterScanOverlaps(db, job)
+	if err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	mode := ingestLatest
+	if stale {
+		mode = ingestStale
+	}
+
+	data, err := artifact.Read()
+	if err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "artifact content is missing from the store"})
+		return
+	}
+	scan, err := models.ParseScan(format, data)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "invalid scan data: " + err.Error()})
+		return
+	}
+
+	ingestJobScan(c, job, &scan, mode)
+}
+
+// laterScanOverlaps reports whether a job of the same team and type completed
+// after job over any of its addresses. Chunks and full-range jobs of a split
+// scan overlap without having the same ip_range.
+func laterScanOverlaps(db *gorm.DB, job models.Job) (bool, error) {
+	var ranges []string
+	err := db.Model(&models.Job{}).
+		Where("t_id = ? AND type = ? AND status = ? AND completed_at > ? AND id != ?",
+			job.TID, job.Type, "complete", job.CompletedAt, job.ID).
+		Distinct().Pluck("ip_range", &ranges).Error
+	if err != nil {
+		return false, err
+	}
+	scope, scopeErr := models.ParseIPSet(job.IPRange)
+	for _, r := range ranges {
+		if r == job.IPRange {
+			return true, nil
+		}
+		later, err := models.ParseIPSet(r)
+		if scopeErr == nil && err == nil && scope.Overlaps(later) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
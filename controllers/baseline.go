@@ -0,0 +1,561 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/brian-l-johnson/Redteam-Dashboard-go/v2/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type BaselineController struct{}
+
+// GetBaselines godoc
+// @Summary Get port baselines
+// @Description Get the port baselines defined for a team
+// @Tags baselines
+// @Accept json
+// @Produce json
+// @Param tid path string true "Team ID"
+// @Success 200 {array} models.PortBaseline
+// @Router /teams/{tid}/baselines [get]
+func (b BaselineController) GetBaselines(c *gin.Context) {
+	db := models.GetDB()
+	var baselines []models.PortBaseline
+
+	result := db.Where("team_id = ?", c.Param("tid")).Order("host_ip ASC, port ASC").Find(&baselines)
+	if result.Error != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, baselines)
+}
+
+// CreateBaseline godoc
+// @Summary Create port baseline
+// @Description Add an expected (or forbidden, with expected=false) port to a team's baseline. host_ip may be "*" for every host in the team.
+// @Tags baselines
+// @Accept json
+// @Produce json
+// @Param tid path string true "Team ID"
+// @Param baseline body models.BaselineRequest true "Baseline data"
+// @Success 201 {object} models.PortBaseline
+// @Router /teams/{tid}/baselines [post]
+func (b BaselineController) CreateBaseline(c *gin.Context) {
+	db := models.GetDB()
+	var team models.Team
+
+	result := db.First(&team, "t_id = ?", c.Param("tid"))
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"status": "error", "message": "team not found"})
+			return
+		}
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
+		return
+	}
+
+	var req models.BaselineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if req.Protocol == "" {
+		req.Protocol = "tcp"
+	}
+	if err := models.ValidateBaseline(req.HostIP, req.Protocol); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	// Check for an existing entry for the same host and port
+	var existing models.PortBaseline
+	result = db.First(&existing, "team_id = ? AND host_ip = ? AND port = ? AND protocol = ?", team.TID, req.HostIP, req.Port, req.Protocol)
+	if result.Error == nil {
+		c.IndentedJSON(http.StatusConflict, gin.H{"status": "error", "message": "baseline already exists for this host and port"})
+		return
+	}
+
+	pb := models.MakeBaseline(team.TID, req.HostIP, req.Port, req.Protocol)
+	pb.Service = req.Service
+	if req.Expected != nil {
+		pb.Expected = *req.Expected
+	}
+
+	result = db.Create(&pb)
+	if result.Error != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
+		return
+	}
+
+	refreshTeamBaseline(team)
+	c.IndentedJSON(http.StatusCreated, pb)
+}
+
+// UpdateBaseline godoc
+// @Summary Update port baseline
+// @Description Update an existing port baseline entry
+// @Tags baselines
+// @Accept json
+// @Produce json
+// @Param bid path string true "Baseline ID"
+// @Param baseline body models.BaselineRequest true "Baseline data"
+// @Success 200 {object} models.PortBaseline
+// @Router /baselines/{bid} [put]
+func (b BaselineController) UpdateBaseline(c *gin.Context) {
+	db := models.GetDB()
+	var pb models.PortBaseline
+
+	result := db.First(&pb, "b_id = ?", c.Param("bid"))
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"status": "error", "message": "baseline not found"})
+			return
+		}
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
+		return
+	}
+
+	var req models.BaselineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if req.Protocol == "" {
+		req.Protocol = "tcp"
+	}
+	if err := models.ValidateBaseline(req.HostIP, req.Protocol); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	// Check if the new host and port conflict with another entry
+	var existing models.PortBaseline
+	result = db.First(&existing, "team_id = ? AND host_ip = ? AND port = ? AND protocol = ? AND b_id != ?", pb.TeamID, req.HostIP, req.Port, req.Protocol, pb.BID)
+	if result.Error == nil {
+		c.IndentedJSON(http.StatusConflict, gin.H{"status": "error", "message": "baseline already exists for this host and port"})
+		return
+	}
+
+	pb.HostIP = req.HostIP
+	pb.Port = req.Port
+	pb.Protocol = req.Protocol
+	pb.Service = req.Service
+	if req.Expected != nil {
+		pb.Expected = *req.Expected
+	}
+
+	result = db.Save(&pb)
+	if result.Error != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
+		return
+	}
+
+	var team models.Team
+	if db.First(&team, "t_id = ?", pb.TeamID).Error == nil {
+		refreshTeamBaseline(team)
+	}
+	c.IndentedJSON(http.StatusOK, pb)
+}
+
+// DeleteBaseline godoc
+// @Summary Delete port baseline
+// @Description Delete a port baseline entry
+// @Tags baselines
+// @Accept json
+// @Produce json
+// @Param bid path string true "Baseline ID"
+// @Success 200 {object} map[string]string
+// @Router /baselines/{bid} [delete]
+func (b BaselineController) DeleteBaseline(c *gin.Context) {
+	db := models.GetDB()
+	var pb models.PortBaseline
+
+	result := db.First(&pb, "b_id = ?", c.Param("bid"))
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"status": "error", "message": "baseline not found"})
+			return
+		}
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
+		return
+	}
+
+	result = db.Delete(&pb)
+	if result.Error != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
+		return
+	}
+
+	var team models.Team
+	if db.First(&team, "t_id = ?", pb.TeamID).Error == nil {
+		refreshTeamBaseline(team)
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{"status": "success", "message": "baseline deleted"})
+}
+
+// SnapshotBaseline godoc
+// @Summary Snapshot observed ports as baseline
+// @Description Copy the open ports of a team's online hosts (or one host, with host_ip) into its baseline.
+// @Description mode=merge keeps existing entries; mode=replace first removes the host-specific entries being snapshotted. "*" entries are never removed.
+// @Tags baselines
+// @Accept json
+// @Produce json
+// @Param tid path string true "Team ID"
+// @Param snapshot body models.BaselineSnapshotRequest false "Snapshot options"
+// @Success 200 {object} map[string]interface{}
+// @Router /teams/{tid}/baselines/snapshot [post]
+func (b BaselineController) SnapshotBaseline(c *gin.Context) {
+	db := models.GetDB()
+	var team models.Team
+
+	result := db.First(&team, "t_id = ?", c.Param("tid"))
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"status": "error", "message": "team not found"})
+			return
+		}
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
+		return
+	}
+
+	var req models.BaselineSnapshotRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	mode, err := baselineMode(req.Mode)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	query := db.Preload("Ports").Where("team_id = ? AND status != ?", team.TID, "offline")
+	if req.HostIP != "" {
+		var host models.Host
+		result = db.First(&host, "team_id = ? AND ip = ?", team.TID, req.HostIP)
+		if result.Error != nil {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"status": "error", "message": "host not found in team"})
+			return
+		}
+		query = db.Preload("Ports").Where("id = ?", host.ID)
+	}
+
+	var hosts []models.Host
+	if err := query.Find(&hosts).Error; err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	tx := db.Begin()
+
+	removed := int64(0)
+	if mode == models.BaselineModeReplace {
+		del := tx.Where("team_id = ? AND host_ip != ?", team.TID, "*")
+		if req.HostIP != "" {
+			del = del.Where("host_ip = ?", req.HostIP)
+		}
+		result = del.Delete(&models.PortBaseline{})
+		if result.Error != nil {
+			tx.Rollback()
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
+			return
+		}
+		removed = result.RowsAffected
+	}
+
+	var entries []models.PortBaseline
+	for _, host := range hosts {
+		for _, port := range host.Ports {
+			pb := models.MakeBaseline(team.TID, host.IP, port.Number, port.Protocol)
+			pb.Service = port.Service
+			entries = append(entries, pb)
+		}
+	}
+
+	created, skipped, err := addBaselines(tx, team.TID, entries)
+	if err != nil {
+		tx.Rollback()
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	refreshTeamBaseline(team)
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"mode":    mode,
+		"created": created,
+		"skipped": skipped,
+		"removed": removed,
+	})
+}
+
+// propagatedTeam reports how a baseline was copied to one team
+type propagatedTeam struct {
+	TeamID   string `json:"team_id"`
+	TeamName string `json:"team_name"`
+	Created  int    `json:"created"`
+	Skipped  int    `json:"skipped"`
+	Unmapped int    `json:"unmapped"`
+	Error    string `json:"error,omitempty"`
+}
+
+// PropagateBaseline godoc
+// @Summary Copy a team's baseline to every other team
+// @Description Copy a team's baseline to all other teams, mapping each host by its offset within the source team's IP range onto the same offset in the target team's range.
+// @Description mode=merge keeps the target teams' entries; mode=replace removes them first.
+// @Tags baselines
+// @Accept json
+// @Produce json
+// @Param tid path string true "Source Team ID"
+// @Param options body models.BaselineSnapshotRequest false "Propagation options (mode only)"
+// @Success 200 {object} map[string]interface{}
+// @Router /teams/{tid}/baselines/propagate [post]
+func (b BaselineController) PropagateBaseline(c *gin.Context) {
+	db := models.GetDB()
+	var source models.Team
+
+	result := db.First(&source, "t_id = ?", c.Param("tid"))
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"status": "error", "message": "team not found"})
+			return
+		}
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
+		return
+	}
+
+	var req models.BaselineSnapshotRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	mode, err := baselineMode(req.Mode)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	sourceSet, err := models.ParseIPSet(source.IPRange)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "source team has an invalid IP range: " + err.Error()})
+		return
+	}
+
+	var baselines []models.PortBaseline
+	if err := db.Where("team_id = ?", source.TID).Find(&baselines).Error; err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if len(baselines) == 0 {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "team has no baseline to propagate"})
+		return
+	}
+
+	var teams []models.Team
+	if err := db.Where("t_id != ?", source.TID).Order("name ASC").Find(&teams).Error; err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	results := []propagatedTeam{}
+	for _, team := range teams {
+		res := propagatedTeam{TeamID: team.TID, TeamName: team.Name}
+
+		targetSet, err := models.ParseIPSet(team.IPRange)
+		if err != nil {
+			res.Error = "invalid IP range: " + err.Error()
+			results = append(results, res)
+			continue
+		}
+
+		// Map each host entry onto the same offset in the target range
+		var entries []models.PortBaseline
+		for _, pb := range baselines {
+			hostIP := pb.HostIP
+			if hostIP != "*" {
+				offset, ok := sourceSet.Offset(hostIP)
+				if ok {
+					hostIP, ok = targetSet.AddrAt(offset)
+				}
+				if !ok {
+					res.Unmapped++
+					continue
+				}
+			}
+			entry := models.MakeBaseline(team.TID, hostIP, pb.Port, pb.Protocol)
+			entry.Service = pb.Service
+			entry.Expected = pb.Expected
+			entries = append(entries, entry)
+		}
+
+		tx := db.Begin()
+		if mode == models.BaselineModeReplace {
+			if err := tx.Where("team_id = ?", team.TID).Delete(&models.PortBaseline{}).Error; err != nil {
+				tx.Rollback()
+				res.Error = err.Error()
+				results = append(results, res)
+				continue
+			}
+		}
+		res.Created, res.Skipped, err = addBaselines(tx, team.TID, entries)
+		if err == nil {
+			err = tx.Commit().Error
+		} else {
+			tx.Rollback()
+		}
+		if err != nil {
+			res.Error = err.Error()
+			res.Created, res.Skipped = 0, 0
+		} else {
+			refreshTeamBaseline(team)
+		}
+		results = append(results, res)
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"status": "success", "mode": mode, "teams": results})
+}
+
+// baselineMode validates a snapshot mode, defaulting to merge
+func baselineMode(mode string) (string, error) {
+	switch mode {
+	case "":
+		return models.BaselineModeMerge, nil
+	case models.BaselineModeMerge, models.BaselineModeReplace:
+		return mode, nil
+	}
+	return "", errors.New("mode must be merge or replace")
+}
+
+// addBaselines creates entries that the team does not already have for the
+// same host and port, returning how many were created and skipped
+func addBaselines(tx *gorm.DB, teamID string, entries []models.PortBaseline) (int, int, error) {
+	var existing []models.PortBaseline
+	if err := tx.Where("team_id = ?", teamID).Find(&existing).Error; err != nil {
+		return 0, 0, err
+	}
+	have := make(map[string]bool)
+	for _, pb := range existing {
+		have[fmt.Sprintf("%s|%d/%s", pb.HostIP, pb.Port, pb.Protocol)] = true
+	}
+
+	created, skipped := 0, 0
+	for i := range entries {
+		key := fmt.Sprintf("%s|%d/%s", entries[i].HostIP, entries[i].Port, entries[i].Protocol)
+		if have[key] {
+			skipped++
+			continue
+		}
+		if err := tx.Create(&entries[i]).Error; err != nil {
+			return 0, 0, err
+		}
+		have[key] = true
+		created++
+	}
+	return created, skipped, nil
+}
+
+// refreshTeamBaseline re-marks every port in a team after its baseline changed
+func refreshTeamBaseline(team models.Team) {
+	scope, err := models.ParseIPSet(team.IPRange)
+	if err != nil {
+		return
+	}
+	if _, _, err := applyBaselines(models.GetDB(), team.TID, scope); err != nil {
+		fmt.Printf("Warning: failed to apply baseline for team %s: %v\n", team.Name, err)
+	}
+}
+
+// applyBaselines marks the open ports of every team host inside scope as
+// baseline or new, and returns how many ports are new and how many expected
+// ports are missing. A host-specific entry takes precedence over a "*" entry.
+// Ports are only marked new once the team has a baseline. Expected "*"
+// entries count as missing on each online host where the port is not open.
+// Ports of offline hosts count as not open, like Snapshot ignores them. An
+// entry with expected=false has no mark of its own: an open port matching it
+// is simply new, as are ports with no entry at all.
+func applyBaselines(tx *gorm.DB, teamID string, scope models.IPSet) (int, int, error) {
+	var baselines []models.PortBaseline
+	if err := tx.Where("team_id = ?", teamID).Find(&baselines).Error; err != nil {
+		return 0, 0, err
+	}
+
+	byHost := make(map[string]*models.PortBaseline)
+	wildcard := make(map[string]*models.PortBaseline)
+	for i := range baselines {
+		key := fmt.Sprintf("%d/%s", baselines[i].Port, strings.ToLower(baselines[i].Protocol))
+		if baselines[i].HostIP == "*" {
+			wildcard[key] = &baselines[i]
+		} else {
+			byHost[baselines[i].HostIP+"|"+key] = &baselines[i]
+		}
+	}
+
+	var hosts []models.Host
+	if err := tx.Preload("Ports").Where("team_id = ?", teamID).Find(&hosts).Error; err != nil {
+		return 0, 0, err
+	}
+
+	newPorts := 0
+	open := make(map[string]bool)
+	for _, host := range hosts {
+		if !scope.Contains(host.IP) {
+			continue
+		}
+		for _, port := range host.Ports {
+			key := fmt.Sprintf("%d/%s", port.Number, port.Protocol)
+			isOpen := host.Status != "offline" && port.State != "closed"
+			open[host.IP+"|"+key] = isOpen
+
+			pb, found := byHost[host.IP+"|"+key]
+			if !found {
+				pb, found = wildcard[key]
+			}
+			isBaseline := isOpen && found && pb.Expected
+			isNew := isOpen && len(baselines) > 0 && !isBaseline
+			if isNew {
+				newPorts++
+			}
+
+			if port.IsBaseline != isBaseline || port.IsNew != isNew {
+				err := tx.Model(&models.Port{}).Where("id = ?", port.ID).
+					Updates(map[string]interface{}{"is_baseline": isBaseline, "is_new": isNew}).Error
+				if err != nil {
+					return 0, 0, err
+				}
+			}
+		}
+	}
+
+	missingPorts := 0
+	for key, pb := range byHost {
+		if pb.Expected && scope.Contains(pb.HostIP) && !open[key] {
+			missingPorts++
+		}
+	}
+	// Wildcard entries are expected on every known host, unless the host has
+	// its own entry for the port
+	for _, host := range hosts {
+		if host.Status == "offline" || !scope.Contains(host.IP) {
+			continue
+		}
+		for key, pb := range wildcard {
+			if _, overridden := byHost[host.IP+"|"+key]; overridden {
+				continue
+			}
+			if pb.Expected && !open[host.IP+"|"+key] {
+				missingPorts++
+			}
+		}
+	}
+
+	return newPorts, missingPorts, nil
+}
@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/brian-l-johnson/Redteam-Dashboard-go/v2/models"
+	"gorm.io/gorm"
+)
+
+// portMarks returns "baseline", "new" or "" for every port of the team,
+// keyed by ip:port/protocol
+func portMarks(t *testing.T, db *gorm.DB, teamID string) map[string]string {
+	t.Helper()
+	var hosts []models.Host
+	if err := db.Preload("Ports").Where("team_id = ?", teamID).Find(&hosts).Error; err != nil {
+		t.Fatal(err)
+	}
+	marks := make(map[string]string)
+	for _, host := range hosts {
+		for _, port := range host.Ports {
+			mark := ""
+			switch {
+			case port.IsBaseline && port.IsNew:
+				mark = "both"
+			case port.IsBaseline:
+				mark = "baseline"
+			case port.IsNew:
+				mark = "new"
+			}
+			marks[fmt.Sprintf("%s:%d/%s", host.IP, port.Number, port.Protocol)] = mark
+		}
+	}
+	return marks
+}
+
+func TestApplyBaselines(t *testing.T) {
+	db := useTestDB(t)
+	team := createTeam(t, db, "team1", "10.0.1.0/24")
+	createHost(t, db, team, "10.0.1.5", "online", 22, 80, 3389)
+	createHost(t, db, team, "10.0.1.6", "online", 22)
+	createHost(t, db, team, "10.0.1.7", "offline", 22, 445)
+	createHost(t, db, team, "10.0.1.200", "online", 8080)
+
+	full, _ := models.ParseIPSet(team.IPRange)
+	lower, _ := models.ParseIPSet("10.0.1.0/25")
+
+	// Without a baseline nothing is new or missing
+	newPorts, missing, err := applyBaselines(db, team.TID, full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newPorts != 0 || missing != 0 {
+		t.Errorf("without a baseline: %d new, %d missing", newPorts, missing)
+	}
+	for key, mark := range portMarks(t, db, team.TID) {
+		if mark != "" {
+			t.Errorf("%s marked %s without a baseline", key, mark)
+		}
+	}
+
+	addBaseline := func(hostIP string, port uint16, protocol string, expected bool) {
+		pb := models.MakeBaseline(team.TID, hostIP, port, protocol)
+		pb.Expected = expected
+		if err := db.Create(&pb).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	addBaseline("*", 22, "tcp", true)
+	addBaseline("*", 53, "udp", true)
+	addBaseline("10.0.1.5", 80, "tcp", true)
+	addBaseline("10.0.1.5", 443, "tcp", true)
+	addBaseline("10.0.1.5", 3389, "tcp", false) // forbidden
+	addBaseline("10.0.1.6", 22, "tcp", false)   // overrides the wildcard
+
+	newPorts, missing, err = applyBaselines(db, team.TID, full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"10.0.1.5:22/tcp":     "baseline",
+		"10.0.1.5:80/tcp":     "baseline",
+		"10.0.1.5:3389/tcp":   "new", // forbidden entries only show as new
+		"10.0.1.6:22/tcp":     "new",
+		"10.0.1.7:22/tcp":     "", // offline hosts are not marked
+		"10.0.1.7:445/tcp":    "",
+		"10.0.1.200:8080/tcp": "new",
+	}
+	got := portMarks(t, db, team.TID)
+	for key, mark := range want {
+		if got[key] != mark {
+			t.Errorf("%s marked %q, want %q", key, got[key], mark)
+		}
+	}
+	if newPorts != 3 {
+		t.Errorf("new ports = %d, want 3", newPorts)
+	}
+	// 443 on .5; 22 on .200; 53/udp on each online host. Neither the
+	// offline host nor .6, with its own entry for 22, adds to the count.
+	if missing != 5 {
+		t.Errorf("missing ports = %d, want 5", missing)
+	}
+
+	// A scan of part of the range only counts its own hosts
+	newPorts, missing, err = applyBaselines(db, team.TID, lower)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newPorts != 2 || missing != 3 {
+		t.Errorf("lower half: %d new, %d missing; want 2 and 3", newPorts, missing)
+	}
+
+	// A host coming back online is marked again, and its expected port
+	// that is not open counts as missing
+	db.Model(&models.Host{}).Where("ip = ?", "10.0.1.7").Update("status", "online")
+	newPorts, missing, err = applyBaselines(db, team.TID, lower)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = portMarks(t, db, team.TID)
+	if got["10.0.1.7:22/tcp"] != "baseline" || got["10.0.1.7:445/tcp"] != "new" {
+		t.Errorf("host back online marked 22 %q and 445 %q", got["10.0.1.7:22/tcp"], got["10.0.1.7:445/tcp"])
+	}
+	if newPorts != 3 || missing != 4 {
+		t.Errorf("with 10.0.1.7 online: %d new, %d missing; want 3 and 4", newPorts, missing)
+	}
+}
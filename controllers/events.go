@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brian-l-johnson/Redteam-Dashboard-go/v2/models"
+	"github.com/gin-gonic/gin"
+)
+
+type EventController struct{}
+
+// eventKeepalive is how often an idle stream sends a comment, so proxies
+// don't close it
+const eventKeepalive = 15 * time.Second
+
+// eventFilter selects the events a stream client asked for
+type eventFilter struct {
+	types  []string // exact types, or categories such as "host"
+	teamID string
+}
+
+func (f eventFilter) matches(e models.Event) bool {
+	if f.teamID != "" && e.TeamID != f.teamID {
+		return false
+	}
+	if len(f.types) == 0 {
+		return true
+	}
+	for _, t := range f.types {
+		if e.Type == t || strings.HasPrefix(e.Type, t+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// writeEvent writes e in server-sent event format
+func writeEvent(c *gin.Context, e models.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+	return err
+}
+
+// StreamEvents godoc
+// @Summary Stream events
+// @Description A server-sent event stream of changes as they happen: host.discovered, host.online, host.offline,
+// @Description port.opened, port.closed, port.changed, finding.created, job.state and job.progress.
+// @Description Each message's event field is the type and its data is a JSON models.Event.
+// @Description A client that reconnects with Last-Event-ID is sent the events it missed; if they are no longer
+// @Description kept it is sent a reset event instead and should reload.
+// @Tags events
+// @Produce text/event-stream
+// @Param types query string false "Comma separated event types or categories (host, port, finding, job)"
+// @Param team_id query string false "Only events for this team"
+// @Success 200 {object} models.Event
+// @Router /events [get]
+func (e EventController) StreamEvents(c *gin.Context) {
+	filter := eventFilter{teamID: c.Query("team_id")}
+	for _, t := range strings.Split(c.Query("types"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			filter.types = append(filter.types, t)
+		}
+	}
+
+	lastID := c.GetHeader("Last-Event-ID")
+	if lastID == "" {
+		lastID = c.Query("last_event_id")
+	}
+	var since uint64
+	if lastID != "" {
+		n, err := strconv.ParseUint(lastID, 10, 64)
+		if err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "invalid Last-Event-ID"})
+			return
+		}
+		since = n
+	}
+
+	sub := models.SubscribeEvents(since)
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	// Tell browsers to wait a few seconds before reconnecting
+	fmt.Fprint(c.Writer, "retry: 3000\n\n")
+	if sub.Gap {
+		fmt.Fprint(c.Writer, "event: reset\ndata: {}\n\n")
+	}
+	for _, ev := range sub.Missed {
+		if filter.matches(ev) {
+			if err := writeEvent(c, ev); err != nil {
+				return
+			}
+		}
+	}
+	c.Writer.Flush()
+
+	keepalive := time.NewTicker(eventKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case ev, ok := <-sub.Events:
+			if !ok {
+				// Dropped for falling behind; the client reconnects and catches up
+				return
+			}
+			if !filter.matches(ev) {
+				continue
+			}
+			if err := writeEvent(c, ev); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(c.Writer, ": keepalive\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
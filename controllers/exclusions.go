@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/brian-l-johnson/Redteam-Dashboard-go/v2/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ExclusionController struct{}
+
+// GetExclusions godoc
+// @Summary Get scan exclusions
+// @Description Get the global and per-team exclusion lists. With team_id, only the exclusions that apply to that team
+// @Description (its own plus the global ones) are returned.
+// @Tags exclusions
+// @Accept json
+// @Produce json
+// @Param team_id query string false "Team ID"
+// @Success 200 {array} models.Exclusion
+// @Router /exclusions [get]
+func (e ExclusionController) GetExclusions(c *gin.Context) {
+	db := models.GetDB()
+	var exclusions []models.Exclusion
+
+	query := db.Order("team_id ASC, id ASC")
+	if tid := c.Query("team_id"); tid != "" {
+		query = query.Where("team_id = ? OR team_id = ?", "", tid)
+	}
+
+	result := query.Find(&exclusions)
+	if result.Error != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, exclusions)
+}
+
+// CreateExclusion godoc
+// @Summary Create scan exclusion
+// @Description Exclude an IP, CIDR or range from scanning, for one team or (without team_id) for every team.
+// @Description Excluded addresses are sent to scanners with each job and dropped from uploaded results.
+// @Tags exclusions
+// @Accept json
+// @Produce json
+// @Param exclusion body models.ExclusionRequest true "Exclusion data"
+// @Success 201 {object} models.Exclusion
+// @Router /exclusions [post]
+func (e ExclusionController) CreateExclusion(c *gin.Context) {
+	var req models.ExclusionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	exclusion := models.MakeExclusion(req.TeamID, req.Target)
+	exclusion.Reason = req.Reason
+	if err := models.ValidateExclusionTarget(exclusion.Target); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	if req.TeamID != "" {
+		var team models.Team
+		if err := db.First(&team, "t_id = ?", req.TeamID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "team not found"})
+				return
+			}
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+			return
+		}
+	}
+
+	var existing models.Exclusion
+	if db.First(&existing, "team_id = ? AND target = ?", exclusion.TeamID, exclusion.Target).Error == nil {
+		c.IndentedJSON(http.StatusConflict, gin.H{"status": "error", "message": "exclusion already exists"})
+		return
+	}
+
+	if err := db.Create(&exclusion).Error; err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusCreated, exclusion)
+}
+
+// DeleteExclusion godoc
+// @Summary Delete scan exclusion
+// @Description Delete a scan exclusion
+// @Tags exclusions
+// @Accept json
+// @Produce json
+// @Param eid path string true "Exclusion ID"
+// @Success 200 {object} map[string]string
+// @Router /exclusions/{eid} [delete]
+func (e ExclusionController) DeleteExclusion(c *gin.Context) {
+	db := models.GetDB()
+	var exclusion models.Exclusion
+
+	if err := db.First(&exclusion, "e_id = ?", c.Param("eid")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"status": "error", "message": "exclusion not found"})
+			return
+		}
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	if err := db.Unscoped().Delete(&exclusion).Error; err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"status": "success", "message": "exclusion deleted"})
+}
@@ -2,7 +2,9 @@ package controllers
 
 import (
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/brian-l-johnson/Redteam-Dashboard-go/v2/models"
 	"github.com/gin-gonic/gin"
@@ -65,6 +67,59 @@ func (h HostController) GetAllHostsByTeam(c *gin.Context) {
 	c.IndentedJSON(http.StatusOK, teams)
 }
 
+// GetPortEvents godoc
+// @Summary Get port event history
+// @Description Get the port event log (opened, closed, service/version changed) for a team, newest first
+// @Tags hosts
+// @Accept json
+// @Produce json
+// @Param tid path string true "Team ID"
+// @Param ip query string false "Only events for this host IP"
+// @Param event query string false "Only this event type (opened, closed, service_changed, version_changed)"
+// @Param since query string false "Only events after this time (RFC3339)"
+// @Param limit query int false "Limit results (default 200, max 1000)"
+// @Success 200 {array} models.PortEvent
+// @Router /hosts/by-team/{tid}/events [get]
+func (h HostController) GetPortEvents(c *gin.Context) {
+	db := models.GetDB()
+	var events []models.PortEvent
+
+	query := db.Where("team_id = ?", c.Param("tid")).Order("time DESC")
+
+	if ip := c.Query("ip"); ip != "" {
+		query = query.Where("host_ip = ?", ip)
+	}
+	if event := c.Query("event"); event != "" {
+		query = query.Where("event = ?", event)
+	}
+	if since := c.Query("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "invalid since time, expected RFC3339"})
+			return
+		}
+		query = query.Where("time > ?", t)
+	}
+
+	limit := 200
+	if l := c.Query("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > 1000 {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "limit must be between 1 and 1000"})
+			return
+		}
+		limit = n
+	}
+
+	results := query.Limit(limit).Find(&events)
+	if results.Error != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": results.Error.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, events)
+}
+
 // GetDashboardData godoc
 // @Summary Get dashboard data
 // @Description Get optimized data for the main dashboard
@@ -83,6 +138,11 @@ func (h HostController) GetDashboardData(c *gin.Context) {
 	totalHosts := 0
 	totalPorts := 0
 	dangerousPorts := 0
+	newPorts := 0
+	missingPorts := 0
+
+	// Drift from the baseline as of each team's most recent scan
+	drift := make(map[string]models.ScanHistory)
 
 	for _, team := range teams {
 		totalHosts += len(team.Hosts)
@@ -92,8 +152,17 @@ func (h HostController) GetDashboardData(c *gin.Context) {
 				if port.IsDangerous() {
 					dangerousPorts++
 				}
+				if port.IsNew {
+					newPorts++
+				}
 			}
 		}
+
+		var latest models.ScanHistory
+		if db.Where("team_id = ?", team.TID).Order("scan_time DESC").First(&latest).Error == nil {
+			drift[team.TID] = latest
+			missingPorts += latest.MissingPorts
+		}
 	}
 
 	// Get recent jobs
@@ -106,13 +175,16 @@ func (h HostController) GetDashboardData(c *gin.Context) {
 		"total_hosts":     totalHosts,
 		"total_ports":     totalPorts,
 		"dangerous_ports": dangerousPorts,
+		"new_ports":       newPorts,
+		"missing_ports":   missingPorts,
+		"drift":           drift,
 		"recent_jobs":     recentJobs,
 	})
 }
 
 // GetVulnerabilities godoc
 // @Summary Get vulnerability findings
-// @Description Get all NSE script findings that indicate vulnerabilities
+// @Description Get all NSE script findings that indicate vulnerabilities, plus imported report and nuclei findings with their scanner-assigned severity
 // @Tags hosts
 // @Accept json
 // @Produce json
@@ -122,7 +194,7 @@ func (h HostController) GetVulnerabilities(c *gin.Context) {
 	db := models.GetDB()
 
 	var teams []models.Team
-	db.Preload("Hosts").Preload("Hosts.Ports").Preload("Hosts.Ports.Scripts").Order("name ASC").Find(&teams)
+	db.Preload("Hosts").Preload("Hosts.Ports").Preload("Hosts.Ports.Scripts").Preload("Hosts.Ports.Findings").Preload("Hosts.Scripts").Order("name ASC").Find(&teams)
 
 	type VulnFinding struct {
 		TeamName   string `json:"team_name"`
@@ -135,6 +207,8 @@ func (h HostController) GetVulnerabilities(c *gin.Context) {
 		ScriptName string `json:"script_name"`
 		Output     string `json:"output"`
 		Severity   string `json:"severity"`
+		Source     string `json:"source"`
+		MatchedAt  string `json:"matched_at,omitempty"`
 	}
 
 	var findings []VulnFinding
@@ -177,9 +251,71 @@ func (h HostController) GetVulnerabilities(c *gin.Context) {
 	
 	for _, team := range teams {
 		for _, host := range team.Hosts {
+			// Report findings about the host as a whole have no port
+			for _, script := range host.Scripts {
+				if script.Severity == "" || script.Severity == models.SeverityInfo {
+					continue
+				}
+				findings = append(findings, VulnFinding{
+					TeamName:   team.Name,
+					TeamID:     team.TID,
+					HostIP:     host.IP,
+					Hostname:   host.Hostname,
+					ScriptName: script.Name,
+					Output:     script.Output,
+					Severity:   script.Severity,
+					Source:     script.Source,
+				})
+			}
+
 			for _, port := range host.Ports {
+				// Templated findings (nuclei) carry their own severity
+				for _, finding := range port.Findings {
+					if finding.Severity == models.SeverityInfo {
+						continue
+					}
+					output := finding.Name
+					if finding.Extracted != "" {
+						output += "\n\n" + finding.Extracted
+					}
+					findings = append(findings, VulnFinding{
+						TeamName:   team.Name,
+						TeamID:     team.TID,
+						HostIP:     host.IP,
+						Hostname:   host.Hostname,
+						Port:       port.Number,
+						Protocol:   port.Protocol,
+						Service:    port.Service,
+						ScriptName: finding.TemplateID,
+						Output:     output,
+						Severity:   finding.Severity,
+						Source:     finding.Source,
+						MatchedAt:  finding.MatchedAt,
+					})
+				}
+
 				// Check script results for vulnerability indicators
 				for _, script := range port.Scripts {
+					// Imported findings carry the scanner's own severity
+					if script.Severity != "" {
+						if script.Severity != models.SeverityInfo {
+							findings = append(findings, VulnFinding{
+								TeamName:   team.Name,
+								TeamID:     team.TID,
+								HostIP:     host.IP,
+								Hostname:   host.Hostname,
+								Port:       port.Number,
+								Protocol:   port.Protocol,
+								Service:    port.Service,
+								ScriptName: script.Name,
+								Output:     script.Output,
+								Severity:   script.Severity,
+								Source:     script.Source,
+							})
+						}
+						continue
+					}
+
 					outputLower := strings.ToLower(script.Output)
 					scriptLower := strings.ToLower(script.Name)
 					
@@ -238,6 +374,7 @@ func (h HostController) GetVulnerabilities(c *gin.Context) {
 							ScriptName: script.Name,
 							Output:     script.Output,
 							Severity:   severity,
+							Source:     "nmap",
 						})
 					}
 				}
@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/brian-l-johnson/Redteam-Dashboard-go/v2/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ImportController struct{}
+
+// ImportReport godoc
+// @Summary Import a scanner report into a team
+// @Description Import a vulnerability scanner report (Nessus .nessus, OpenVAS XML or nuclei JSONL) into a team's hosts, ports and findings.
+// @Description Ports and findings are merged with existing data; nothing is marked offline.
+// @Tags import
+// @Accept xml,json
+// @Produce json
+// @Param tid path string true "Team ID"
+// @Param format query string true "Report format: nessus, openvas, nuclei"
+// @Success 200 {object} map[string]interface{}
+// @Router /teams/{tid}/import [post]
+func (i ImportController) ImportReport(c *gin.Context) {
+	db := models.GetDB()
+
+	var team models.Team
+	if err := db.First(&team, "t_id = ?", c.Param("tid")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"status": "error", "message": "team not found"})
+			return
+		}
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	format := c.Query("format")
+	switch format {
+	case models.ScanFormatNessus, models.ScanFormatOpenVAS, models.ScanFormatNuclei:
+	default:
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "unsupported report format: " + format})
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "unable to read request body"})
+		return
+	}
+
+	scan, err := models.ParseScan(format, body)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	txdb, events := models.BufferEvents(db)
+	tx := txdb.Begin()
+
+	// Hosts outside the team's range are rejected or rerouted to their own team
+	scans, rejected, rerouted, err := splitScanByScope(tx, team, &scan)
+	if err != nil {
+		tx.Rollback()
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	res := ingestResult{}
+	for tid, teamScan := range scans {
+		teamRes, err := mergeScan(tx, tid, "", teamScan, models.AllPorts)
+		if err != nil {
+			tx.Rollback()
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+			return
+		}
+		res.Hosts += teamRes.Hosts
+		res.Ports += teamRes.Ports
+		res.Scripts += teamRes.Scripts
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	events.Publish()
+
+	// Mark imported ports against each team's baseline
+	for tid := range scans {
+		var scanned models.Team
+		if db.First(&scanned, "t_id = ?", tid).Error == nil {
+			refreshTeamBaseline(scanned)
+		}
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status":            "success",
+		"hosts_processed":   res.Hosts,
+		"ports_processed":   res.Ports,
+		"scripts_processed": res.Scripts,
+		"rejected_hosts":    rejected,
+		"rerouted_hosts":    rerouted,
+	})
+}
@@ -0,0 +1,577 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/brian-l-johnson/Redteam-Dashboard-go/v2/models"
+	"gorm.io/gorm"
+)
+
+// ingestResult summarises what mergeScan wrote to the database
+type ingestResult struct {
+	Hosts   int
+	Ports   int
+	Scripts int
+	// Remaining holds the team's existing hosts that were not in the scan
+	Remaining map[string]*models.Host
+}
+
+// rejectedHost is an uploaded host that was not stored
+type rejectedHost struct {
+	IP    string `json:"ip"`
+	Error string `json:"error"`
+}
+
+// reroutedHost is an uploaded host that was stored for a different team
+type reroutedHost struct {
+	IP       string `json:"ip"`
+	TeamID   string `json:"team_id"`
+	TeamName string `json:"team_name"`
+}
+
+// Handling of uploaded hosts outside the team's IP range, set with OUT_OF_SCOPE_HOSTS
+const (
+	outOfScopeReject  = "reject"  // drop the host and report it (default)
+	outOfScopeReroute = "reroute" // attach the host to the team whose range contains it
+)
+
+func outOfScopeMode() string {
+	if os.Getenv("OUT_OF_SCOPE_HOSTS") == outOfScopeReroute {
+		return outOfScopeReroute
+	}
+	return outOfScopeReject
+}
+
+// splitScanByScope checks every host in scan against the team's IP range
+// and the exclusion lists. It returns the scan to merge for each team, keyed
+// by TID (the upload's own team is always present), plus the hosts that were
+// rejected or rerouted.
+func splitScanByScope(tx *gorm.DB, team models.Team, scan *models.Scan) (map[string]*models.Scan, []rejectedHost, []reroutedHost, error) {
+	scans := map[string]*models.Scan{team.TID: newScopedScan(scan)}
+	rejected := []rejectedHost{}
+	rerouted := []reroutedHost{}
+
+	// Excluded addresses are never stored, whichever team they land in
+	exclusions := make(map[string]models.ExclusionList)
+	excluded := func(tid string, ip string) (bool, error) {
+		list, ok := exclusions[tid]
+		if !ok {
+			var err error
+			if list, err = models.TeamExclusions(tx, tid); err != nil {
+				return false, err
+			}
+			exclusions[tid] = list
+		}
+		return list.Contains(ip), nil
+	}
+
+	teamScope, err := models.ParseIPSet(team.IPRange)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("team %s has an invalid IP range: %v", team.Name, err)
+	}
+
+	var others []models.Team
+	reroute := outOfScopeMode() == outOfScopeReroute
+	if reroute {
+		if err := tx.Where("t_id != ?", team.TID).Order("name ASC").Find(&others).Error; err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	for _, scanHost := range scan.Hosts {
+		if teamScope.Contains(scanHost.IP) {
+			skip, err := excluded(team.TID, scanHost.IP)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			if skip {
+				rejected = append(rejected, rejectedHost{IP: scanHost.IP, Error: "excluded from scanning"})
+				continue
+			}
+			scans[team.TID].Hosts = append(scans[team.TID].Hosts, scanHost)
+			continue
+		}
+
+		var owner *models.Team
+		for i := range others {
+			if scope, err := models.ParseIPSet(others[i].IPRange); err == nil && scope.Contains(scanHost.IP) {
+				owner = &others[i]
+				break
+			}
+		}
+		if owner == nil {
+			msg := "outside team IP range " + team.IPRange
+			if reroute {
+				msg = "outside the IP range of every team"
+			}
+			rejected = append(rejected, rejectedHost{IP: scanHost.IP, Error: msg})
+			continue
+		}
+
+		skip, err := excluded(owner.TID, scanHost.IP)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if skip {
+			rejected = append(rejected, rejectedHost{IP: scanHost.IP, Error: "excluded from scanning"})
+			continue
+		}
+
+		if scans[owner.TID] == nil {
+			scans[owner.TID] = newScopedScan(scan)
+		}
+		scans[owner.TID].Hosts = append(scans[owner.TID].Hosts, scanHost)
+		rerouted = append(rerouted, reroutedHost{IP: scanHost.IP, TeamID: owner.TID, TeamName: owner.Name})
+	}
+
+	return scans, rejected, rerouted, nil
+}
+
+// newScopedScan copies a scan's metadata without its hosts
+func newScopedScan(scan *models.Scan) *models.Scan {
+	scoped := *scan
+	scoped.Hosts = []models.ScanHost{}
+	return &scoped
+}
+
+// jobCoverage returns the ports a job's scan probed: those of its job type's
+// profile, with an ad hoc job's own port list in place
+func jobCoverage(tx *gorm.DB, job models.Job) (models.PortCoverage, error) {
+	var js models.JobStatus
+	err := tx.First(&js, "name = ?", job.Type).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// The job type was removed since, so what it scanned is unknown
+		return models.PortCoverage{}, nil
+	}
+	if err != nil {
+		return models.PortCoverage{}, err
+	}
+	return js.ProfileFor(job).Coverage(), nil
+}
+
+// mergeScan merges scan results into the hosts and ports of a team.
+// Full scans reconcile each host's port list, closing ports that are no
+// longer open; port-only scans add new ports and refresh existing ones
+// without touching their service/version data. Every change is recorded as
+// a PortEvent against jid (empty for report imports). Only ports in scanned
+// were probed, so only they can be closed.
+func mergeScan(tx *gorm.DB, teamID string, jid string, scan *models.Scan, scanned models.PortCoverage) (ingestResult, error) {
+	res := ingestResult{}
+
+	// Get existing hosts for this team
+	var existingHosts []models.Host
+	if err := tx.Where("team_id = ?", teamID).Find(&existingHosts).Error; err != nil {
+		return res, err
+	}
+
+	// Create a map of existing hosts by IP for quick lookup
+	existingHostMap := make(map[string]*models.Host)
+	for i := range existingHosts {
+		existingHostMap[existingHosts[i].IP] = &existingHosts[i]
+	}
+
+	for _, scanHost := range scan.Hosts {
+		var host *models.Host
+
+		if existing, found := existingHostMap[scanHost.IP]; found {
+			// Update existing host
+			host = existing
+			if !scan.PortsOnly || scanHost.Hostname != "" {
+				host.Hostname = scanHost.Hostname
+			}
+			if !scan.PortsOnly || scanHost.OS != "" {
+				host.OS = scanHost.OS
+			}
+			host.LastSeen = time.Now()
+			if host.Status != "online" {
+				host.Status = "online"
+				models.RaiseEvent(tx, models.EventHostOnline, teamID, hostEvent(host, jid))
+			}
+		} else {
+			// Create new host
+			newHost := models.Host{
+				IP:       scanHost.IP,
+				Hostname: scanHost.Hostname,
+				OS:       scanHost.OS,
+				TeamID:   teamID,
+				LastSeen: time.Now(),
+				Status:   "online",
+			}
+			if err := tx.Create(&newHost).Error; err != nil {
+				return res, err
+			}
+			host = &newHost
+			models.RaiseEvent(tx, models.EventHostDiscovered, teamID, hostEvent(host, jid))
+		}
+
+		ports, scripts, err := mergePorts(tx, host, jid, scanHost.Ports, !scan.PortsOnly, scanned)
+		if err != nil {
+			return res, err
+		}
+		res.Ports += ports
+		res.Scripts += scripts
+
+		scripts, err = mergeHostScripts(tx, host, scanHost.Scripts, !scan.PortsOnly)
+		if err != nil {
+			return res, err
+		}
+		res.Scripts += scripts
+
+		if err := tx.Save(host).Error; err != nil {
+			return res, err
+		}
+
+		res.Hosts++
+		delete(existingHostMap, scanHost.IP)
+	}
+
+	res.Remaining = existingHostMap
+	return res, nil
+}
+
+// mergePorts reconciles a host's ports with the scanned ones. Known ports are
+// updated in place so their history is kept; script results are replaced per
+// source. When full is set the scan is authoritative: service/version changes
+// are applied, NSE results are always replaced and ports missing from the
+// scan are closed if scanned says the scan probed them.
+func mergePorts(tx *gorm.DB, host *models.Host, jid string, scanPorts []models.ScanPort, full bool, scanned models.PortCoverage) (int, int, error) {
+	var existingPorts []models.Port
+	if err := tx.Where("host_id = ?", host.ID).Find(&existingPorts).Error; err != nil {
+		return 0, 0, err
+	}
+
+	existingPortMap := make(map[string]*models.Port)
+	for i := range existingPorts {
+		existingPortMap[fmt.Sprintf("%d/%s", existingPorts[i].Number, existingPorts[i].Protocol)] = &existingPorts[i]
+	}
+
+	now := time.Now()
+	portsProcessed := 0
+	scriptsProcessed := 0
+	for _, scanPort := range scanPorts {
+		key := fmt.Sprintf("%d/%s", scanPort.Number, scanPort.Protocol)
+		known := make(map[string]bool)
+		port, found := existingPortMap[key]
+		if found {
+			delete(existingPortMap, key)
+
+			if scanPort.Service != "" && scanPort.Service != port.Service && (full || port.Service == "") {
+				recordPortEvent(tx, host, port, jid, models.PortEventServiceChanged, port.Service, scanPort.Service)
+				port.Service = scanPort.Service
+			}
+			if scanPort.Version != "" && scanPort.Version != port.Version && (full || port.Version == "") {
+				recordPortEvent(tx, host, port, jid, models.PortEventVersionChanged, port.Version, scanPort.Version)
+				port.Version = scanPort.Version
+			}
+			port.State = scanPort.State
+			port.LastSeen = now
+			if err := tx.Save(port).Error; err != nil {
+				return portsProcessed, scriptsProcessed, err
+			}
+
+			sources := make(map[string]bool)
+			if full {
+				sources[""] = true
+			}
+			for _, script := range scanPort.Scripts {
+				sources[script.Source] = true
+			}
+			var err error
+			if known, err = scriptKeys(tx.Model(&models.ScriptResult{}).Where("port_id = ?", port.ID)); err != nil {
+				return portsProcessed, scriptsProcessed, err
+			}
+			for source := range sources {
+				tx.Where("port_id = ? AND source = ?", port.ID, source).Delete(&models.ScriptResult{})
+			}
+		} else {
+			var err error
+			port, err = openPort(tx, host, jid, scanPort, now)
+			if err != nil {
+				return portsProcessed, scriptsProcessed, err
+			}
+		}
+
+		scriptsProcessed += createScripts(tx, host, port, known, scanPort.Scripts)
+		scriptsProcessed += saveFindings(tx, host, port, scanPort.Findings)
+		portsProcessed++
+	}
+
+	if full {
+		for _, port := range existingPortMap {
+			if !scanned.Contains(port.Number, port.Protocol) {
+				continue
+			}
+			recordPortEvent(tx, host, port, jid, models.PortEventClosed, port.State, "closed")
+			port.State = "closed"
+			tx.Save(port)
+			// Closed ports are soft deleted and restored if they open again
+			tx.Delete(port)
+		}
+	}
+
+	return portsProcessed, scriptsProcessed, nil
+}
+
+// mergeHostScripts replaces a host's own script results per source, the way
+// mergePorts does for a port's
+func mergeHostScripts(tx *gorm.DB, host *models.Host, scanScripts []models.ScanScriptResult, full bool) (int, error) {
+	sources := make(map[string]bool)
+	if full {
+		sources[""] = true
+	}
+	for _, script := range scanScripts {
+		sources[script.Source] = true
+	}
+	known, err := scriptKeys(tx.Model(&models.HostScript{}).Where("host_id = ?", host.ID))
+	if err != nil {
+		return 0, err
+	}
+	for source := range sources {
+		if err := tx.Where("host_id = ? AND source = ?", host.ID, source).Delete(&models.HostScript{}).Error; err != nil {
+			return 0, err
+		}
+	}
+
+	scriptsProcessed := 0
+	for _, scanScript := range scanScripts {
+		if scanScript.Name == "" || scanScript.Output == "" {
+			continue
+		}
+		script := models.HostScript{
+			HostID:   host.ID,
+			Name:     scanScript.Name,
+			Output:   scanScript.Output,
+			Severity: scanScript.Severity,
+			Source:   scanScript.Source,
+		}
+		if err := tx.Create(&script).Error; err != nil {
+			return scriptsProcessed, err
+		}
+		scriptsProcessed++
+		raiseScriptFinding(tx, host, nil, scanScript, known)
+	}
+	return scriptsProcessed, nil
+}
+
+// openPort adds a newly opened port to a host. A port that was open before
+// and later closed is restored so it keeps its first-seen time.
+func openPort(tx *gorm.DB, host *models.Host, jid string, scanPort models.ScanPort, now time.Time) (*models.Port, error) {
+	var port models.Port
+	err := tx.Unscoped().
+		Where("host_id = ? AND number = ? AND protocol = ? AND deleted_at IS NOT NULL", host.ID, scanPort.Number, scanPort.Protocol).
+		Order("deleted_at DESC").
+		First(&port).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if err == nil {
+		// Script results from before the port closed are stale
+		tx.Where("port_id = ?", port.ID).Delete(&models.ScriptResult{})
+		port.DeletedAt = gorm.DeletedAt{}
+		if scanPort.Service != "" {
+			port.Service = scanPort.Service
+		}
+		if scanPort.Version != "" {
+			port.Version = scanPort.Version
+		}
+	} else {
+		port = models.Port{
+			Number:    scanPort.Number,
+			Protocol:  scanPort.Protocol,
+			Service:   scanPort.Service,
+			Version:   scanPort.Version,
+			HostID:    host.ID,
+			FirstSeen: now,
+		}
+	}
+	port.State = scanPort.State
+	port.LastSeen = now
+
+	if err := tx.Unscoped().Save(&port).Error; err != nil {
+		return nil, err
+	}
+
+	recordPortEvent(tx, host, &port, jid, models.PortEventOpened, "", port.Service)
+	return &port, nil
+}
+
+// recordPortEvent adds an entry to the port event log
+func recordPortEvent(tx *gorm.DB, host *models.Host, port *models.Port, jid string, event string, oldValue string, newValue string) {
+	pe := models.PortEvent{
+		TeamID:   host.TeamID,
+		HostID:   host.ID,
+		HostIP:   host.IP,
+		PortID:   port.ID,
+		Number:   port.Number,
+		Protocol: port.Protocol,
+		Event:    event,
+		OldValue: oldValue,
+		NewValue: newValue,
+		JID:      jid,
+		Time:     time.Now(),
+	}
+	if err := tx.Create(&pe).Error; err != nil {
+		// Log but don't fail the upload on event log errors
+		fmt.Printf("Warning: failed to record %s event for %s port %d: %v\n", event, host.IP, port.Number, err)
+	}
+
+	eventType := models.EventPortChanged
+	switch event {
+	case models.PortEventOpened:
+		eventType = models.EventPortOpened
+	case models.PortEventClosed:
+		eventType = models.EventPortClosed
+	}
+	// Changes are applied after the event is recorded, so send the new value
+	change := models.PortChangeEvent{PortEvent: pe, Service: port.Service, Version: port.Version, State: port.State}
+	switch event {
+	case models.PortEventServiceChanged:
+		change.Service = newValue
+	case models.PortEventVersionChanged:
+		change.Version = newValue
+	case models.PortEventClosed:
+		change.State = "closed"
+	}
+	models.RaiseEvent(tx, eventType, host.TeamID, change)
+}
+
+// hostEvent is the event stream data for a host
+func hostEvent(host *models.Host, jid string) models.HostEvent {
+	return models.HostEvent{
+		TeamID:   host.TeamID,
+		IP:       host.IP,
+		Hostname: host.Hostname,
+		OS:       host.OS,
+		Status:   host.Status,
+		JID:      jid,
+	}
+}
+
+// createScripts saves script results for a port, skipping empty ones. Rated
+// results that are not in known are announced as new findings.
+func createScripts(tx *gorm.DB, host *models.Host, port *models.Port, known map[string]bool, scanScripts []models.ScanScriptResult) int {
+	scriptsProcessed := 0
+	for _, scanScript := range scanScripts {
+		if scanScript.Name != "" && scanScript.Output != "" {
+			dbScript := models.ScriptResult{
+				PortID:   port.ID,
+				Name:     scanScript.Name,
+				Output:   scanScript.Output,
+				Severity: scanScript.Severity,
+				Source:   scanScript.Source,
+			}
+			if err := tx.Create(&dbScript).Error; err != nil {
+				// Log but don't fail on script save errors
+				fmt.Printf("Warning: failed to save script result for %s: %v\n", scanScript.Name, err)
+			} else {
+				scriptsProcessed++
+				raiseScriptFinding(tx, host, port, scanScript, known)
+			}
+		}
+	}
+	return scriptsProcessed
+}
+
+// scriptKeys returns the source and name of each script result query
+// matches. Script results are replaced on every scan, so this is how a result
+// that was already there is told apart from a new one.
+func scriptKeys(query *gorm.DB) (map[string]bool, error) {
+	var rows []struct {
+		Source string
+		Name   string
+	}
+	if err := query.Select("source, name").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	keys := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		keys[row.Source+"|"+row.Name] = true
+	}
+	return keys, nil
+}
+
+// raiseScriptFinding announces a scanner-rated script result (Nessus, OpenVAS)
+// that is not in known. port is nil for results about the whole host.
+// Unrated NSE output is classified when the vulnerability list is loaded,
+// so it is picked up when the job's completion reloads the list.
+func raiseScriptFinding(tx *gorm.DB, host *models.Host, port *models.Port, script models.ScanScriptResult, known map[string]bool) {
+	key := script.Source + "|" + script.Name
+	if script.Severity == "" || known[key] {
+		return
+	}
+	known[key] = true
+
+	event := models.FindingEvent{
+		TeamID:     host.TeamID,
+		HostIP:     host.IP,
+		Hostname:   host.Hostname,
+		Source:     script.Source,
+		TemplateID: script.Name,
+		Name:       script.Name,
+		Severity:   script.Severity,
+		Output:     script.Output,
+	}
+	if port != nil {
+		event.Port = port.Number
+		event.Protocol = port.Protocol
+		event.Service = port.Service
+	}
+	models.RaiseEvent(tx, models.EventFindingCreated, host.TeamID, event)
+}
+
+// saveFindings records templated findings for a port. A finding already seen
+// for the same template and match location is refreshed rather than duplicated.
+func saveFindings(tx *gorm.DB, host *models.Host, port *models.Port, scanFindings []models.ScanFinding) int {
+	portID := port.ID
+	findingsProcessed := 0
+	for _, scanFinding := range scanFindings {
+		var finding models.Finding
+		err := tx.Where("port_id = ? AND source = ? AND template_id = ? AND matched_at = ?",
+			portID, scanFinding.Source, scanFinding.TemplateID, scanFinding.MatchedAt).First(&finding).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			fmt.Printf("Warning: failed to look up finding %s: %v\n", scanFinding.TemplateID, err)
+			continue
+		}
+
+		finding.PortID = portID
+		finding.Source = scanFinding.Source
+		finding.TemplateID = scanFinding.TemplateID
+		finding.Name = scanFinding.Name
+		finding.Severity = scanFinding.Severity
+		finding.MatchedAt = scanFinding.MatchedAt
+		finding.Extracted = strings.Join(scanFinding.Extracted, "\n")
+		finding.Description = scanFinding.Description
+		finding.LastSeen = time.Now()
+
+		created := finding.ID == 0
+		if err := tx.Save(&finding).Error; err != nil {
+			// Log but don't fail on finding save errors
+			fmt.Printf("Warning: failed to save finding %s: %v\n", scanFinding.TemplateID, err)
+			continue
+		}
+		findingsProcessed++
+
+		if created {
+			models.RaiseEvent(tx, models.EventFindingCreated, host.TeamID, models.FindingEvent{
+				TeamID:     host.TeamID,
+				HostIP:     host.IP,
+				Hostname:   host.Hostname,
+				Port:       port.Number,
+				Protocol:   port.Protocol,
+				Service:    port.Service,
+				Source:     finding.Source,
+				TemplateID: finding.TemplateID,
+				Name:       finding.Name,
+				Severity:   finding.Severity,
+				MatchedAt:  finding.MatchedAt,
+				Extracted:  finding.Extracted,
+			})
+		}
+	}
+	return findingsProcessed
+}
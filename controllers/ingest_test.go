@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/brian-l-johnson/Redteam-Dashboard-go/v2/models"
+	"gorm.io/gorm"
+)
+
+func openPorts(ports ...models.ScanPort) []models.ScanPort {
+	for i := range ports {
+		ports[i].State = "open"
+		ports[i].Protocol = "tcp"
+	}
+	return ports
+}
+
+func TestMergeScanPortLifecycle(t *testing.T) {
+	db := useTestDB(t)
+	team := createTeam(t, db, "team1", "10.0.1.0/24")
+
+	merge := func(scan models.Scan, scanned models.PortCoverage) {
+		t.Helper()
+		err := db.Transaction(func(tx *gorm.DB) error {
+			_, err := mergeScan(tx, team.TID, "job-1", &scan, scanned)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	portsOf := func() map[uint16]models.Port {
+		t.Helper()
+		ports := make(map[uint16]models.Port)
+		for _, port := range findHost(t, db, team.TID, "10.0.1.5").Ports {
+			ports[port.Number] = port
+		}
+		return ports
+	}
+
+	merge(models.Scan{Hosts: []models.ScanHost{{IP: "10.0.1.5", Status: "up", Ports: openPorts(
+		models.ScanPort{Number: 22, Service: "ssh"},
+		models.ScanPort{Number: 80, Service: "http"},
+		models.ScanPort{Number: 8443, Service: "https-alt"},
+	)}}}, models.AllPorts)
+	first := portsOf()
+	if len(first) != 3 {
+		t.Fatalf("after the first scan: %d ports, want 3", len(first))
+	}
+
+	// A scan of ports 1-100 that no longer finds 80 closes it, but says
+	// nothing about 8443
+	low, err := models.ParsePortList("1-100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	merge(models.Scan{Hosts: []models.ScanHost{{IP: "10.0.1.5", Status: "up", Ports: openPorts(
+		models.ScanPort{Number: 22, Service: "ssh"},
+	)}}}, models.PortCoverage{Ports: low})
+	second := portsOf()
+	if _, open := second[80]; open {
+		t.Error("port 80 is still listed after a scan covering it came back without it")
+	}
+	if _, open := second[8443]; !open {
+		t.Error("port 8443 was closed by a scan that did not probe it")
+	}
+
+	// The closed port is kept, soft deleted, with its closing in the log
+	var closed models.Port
+	if err := db.Unscoped().First(&closed, first[80].ID).Error; err != nil {
+		t.Fatalf("closed port was removed: %v", err)
+	}
+	if closed.State != "closed" || !closed.DeletedAt.Valid {
+		t.Errorf("closed port stored as %q, deleted %v", closed.State, closed.DeletedAt.Valid)
+	}
+	var events int64
+	db.Model(&models.PortEvent{}).Where("port_id = ? AND event = ?", closed.ID, models.PortEventClosed).Count(&events)
+	if events != 1 {
+		t.Errorf("%d closed events for port 80, want 1", events)
+	}
+
+	// A port sweep reopens it as the same row, keeping when it was first
+	// seen, and does not overwrite the service nmap found on 22
+	merge(models.Scan{PortsOnly: true, Hosts: []models.ScanHost{{IP: "10.0.1.5", Status: "up", Ports: openPorts(
+		models.ScanPort{Number: 22, Service: "unknown"},
+		models.ScanPort{Number: 80},
+	)}}}, models.AllPorts)
+	third := portsOf()
+	reopened, ok := third[80]
+	if !ok {
+		t.Fatal("port 80 was not reopened")
+	}
+	if reopened.ID != first[80].ID || !reopened.FirstSeen.Equal(first[80].FirstSeen) {
+		t.Errorf("reopened port is row %d first seen %v, want row %d first seen %v",
+			reopened.ID, reopened.FirstSeen, first[80].ID, first[80].FirstSeen)
+	}
+	if reopened.Service != "http" {
+		t.Errorf("reopened port lost its service: %q", reopened.Service)
+	}
+	if third[22].Service != "ssh" {
+		t.Errorf("port sweep changed port 22's service to %q", third[22].Service)
+	}
+	if len(third) != 3 {
+		t.Errorf("%d ports after the sweep, want 3", len(third))
+	}
+}
+
+func TestIngestPartialScanScope(t *testing.T) {
+	db := useTestDB(t)
+	team := createTeam(t, db, "team1", "10.0.1.0/24")
+	createHost(t, db, team, "10.0.1.5", "online", 22)
+	createHost(t, db, team, "10.0.1.200", "online", 22)
+
+	// A chunk covering the lower half of the range finds a new host only
+	job := models.MakeJob("nmap", "10.0.1.0/25", team.TID, team.Name)
+	job.Status = "running"
+	db.Create(&job)
+	scan := models.Scan{Hosts: []models.ScanHost{{IP: "10.0.1.10", Status: "up", Ports: openPorts(models.ScanPort{Number: 80})}}}
+
+	c, w := testContext()
+	ingestJobScan(c, job, &scan, ingestUpload)
+	if w.Code != http.StatusOK {
+		t.Fatalf("upload: %d %s", w.Code, w.Body.String())
+	}
+
+	for ip, want := range map[string]string{
+		"10.0.1.5":   "offline", // in the chunk, not found
+		"10.0.1.10":  "online",  // found
+		"10.0.1.200": "online",  // outside the chunk, left alone
+	} {
+		if got := findHost(t, db, team.TID, ip).Status; got != want {
+			t.Errorf("%s is %s, want %s", ip, got, want)
+		}
+	}
+
+	var stored models.Job
+	db.First(&stored, job.ID)
+	if stored.Status != "complete" || stored.HostsFound != 1 || stored.CompletedAt.Before(time.Now().Add(-time.Minute)) {
+		t.Errorf("job stored as %s with %d hosts, completed %v", stored.Status, stored.HostsFound, stored.CompletedAt)
+	}
+
+	// The upload cannot be accepted twice
+	c, w = testContext()
+	ingestJobScan(c, job, &scan, ingestUpload)
+	if w.Code != http.StatusConflict {
+		t.Errorf("second upload of a completed job: %d, want 409", w.Code)
+	}
+}
+
+func TestIngestStaleScanOnlyAdds(t *testing.T) {
+	db := useTestDB(t)
+	team := createTeam(t, db, "team1", "10.0.1.0/24")
+	createHost(t, db, team, "10.0.1.5", "online", 22, 443)
+
+	job := models.MakeJob("nmap", team.IPRange, team.TID, team.Name)
+	job.Status = "complete"
+	db.Create(&job)
+
+	// An older scan that saw a different host and only port 22 on .5
+	scan := models.Scan{Hosts: []models.ScanHost{
+		{IP: "10.0.1.5", Status: "up", Ports: openPorts(models.ScanPort{Number: 22})},
+		{IP: "10.0.1.6", Status: "up", Ports: openPorts(models.ScanPort{Number: 3389})},
+	}}
+	c, w := testContext()
+	ingestJobScan(c, job, &scan, ingestStale)
+	if w.Code != http.StatusOK {
+		t.Fatalf("re-ingest: %d %s", w.Code, w.Body.String())
+	}
+
+	host := findHost(t, db, team.TID, "10.0.1.5")
+	if host.Status != "online" || len(host.Ports) != 2 {
+		t.Errorf("stale re-ingest changed 10.0.1.5: %s with %d ports", host.Status, len(host.Ports))
+	}
+	if added := findHost(t, db, team.TID, "10.0.1.6"); len(added.Ports) != 1 {
+		t.Errorf("stale re-ingest added %d ports to 10.0.1.6, want 1", len(added.Ports))
+	}
+}
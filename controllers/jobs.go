@@ -3,7 +3,11 @@ package controllers
 import (
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/brian-l-johnson/Redteam-Dashboard-go/v2/models"
@@ -15,7 +19,8 @@ type JobController struct{}
 
 // GetJobManagerState godoc
 // @Summary Get job manager state
-// @Description Get the current state of job schedulers
+// @Description Get the registered job types with their scan profiles and scheduler state.
+// @Description Each job type's queue lists the teams in the order its strategy will hand them out.
 // @Tags jobs
 // @Accept json
 // @Produce json
@@ -24,110 +29,333 @@ type JobController struct{}
 func (j JobController) GetJobManagerState(c *gin.Context) {
 	db := models.GetDB()
 	var js []models.JobStatus
-	results := db.Find(&js)
+	results := db.Order("name ASC").Find(&js)
 	if results.Error != nil {
 		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": results.Error.Error()})
 		return
 	}
+	for i := range js {
+		js[i].Argv = js[i].CommandArgs()
+		queue, err := js[i].TeamQueue()
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+			return
+		}
+		js[i].Queue = queue
+	}
 	c.IndentedJSON(http.StatusOK, js)
 }
 
 // NewJob godoc
 // @Summary Get next job
-// @Description Get the next job to process for a given job type
+// @Description Get the next job to process for a given job type. Queued ad hoc jobs are handed out first, then requeued jobs,
+// @Description before the job type's scheduling strategy picks a team.
+// @Description The job is leased until lease_expires_at; call the heartbeat endpoint to keep it.
+// @Description The job type's scan profile is included, with argv holding the ready-made scanner arguments.
+// @Description Only teams whose schedule is due are handed out; otherwise a 404 carries retry_after and a Retry-After header.
+// @Description The agent is identified by the X-Scanner-Name header (default: the account name) and recorded on the job.
+// @Description Teams with scanner tags are only handed to scanners carrying one of those tags.
+// @Description exclude lists the global and team exclusions, which are also added to argv as --exclude.
+// @Description Nothing is handed out while the queue is paused, or for teams in a blackout or outside their allow windows.
+// @Description With wait, a request that finds no work is held open for up to that many seconds until a job is available.
 // @Tags jobs
 // @Accept json
 // @Produce json
 // @Param jobtype path string true "Job Type (e.g., nmap)"
+// @Param wait query int false "Seconds to wait for work (max 300)"
 // @Success 200 {object} models.Job
 // @Router /jobs/{jobtype}/next [get]
 func (j JobController) NewJob(c *gin.Context) {
-	db := models.GetDB()
+	var wait time.Duration
+	if w := c.Query("wait"); w != "" {
+		secs, err := strconv.Atoi(w)
+		if err != nil || secs < 0 || secs > maxJobWaitSeconds {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": fmt.Sprintf("wait must be between 0 and %d seconds", maxJobWaitSeconds)})
+			return
+		}
+		wait = time.Duration(secs) * time.Second
+	}
+
+	// Without work, hold the request open until something changes, the
+	// next team falls due or the wait runs out
+	deadline := time.Now().Add(wait)
+	for {
+		changed := models.WorkChanged()
+
+		// Loaded on every pass, so a long wait sees job type and scanner
+		// tag changes made meanwhile
+		js, scanner, ok := loadJobRequest(c)
+		if !ok {
+			return
+		}
+		job, err := handOutJob(&js, &scanner)
+		if err == nil {
+			if err := job.AttachExclusions(); err != nil {
+				c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+				return
+			}
+			c.IndentedJSON(http.StatusOK, job)
+			return
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 || !canWaitFor(err) {
+			writeNoWork(c, err)
+			return
+		}
+		var noWork *models.NoWorkError
+		if errors.As(err, &noWork) && noWork.RetryAfter < remaining {
+			remaining = noWork.RetryAfter
+		}
 
+		timer := time.NewTimer(remaining)
+		select {
+		case <-changed:
+		case <-timer.C:
+		case <-c.Request.Context().Done():
+			timer.Stop()
+			return
+		}
+		timer.Stop()
+	}
+}
+
+// loadJobRequest loads the job type and scanner of a job request, writing an
+// error response if either is unusable
+func loadJobRequest(c *gin.Context) (models.JobStatus, models.Scanner, bool) {
 	var js models.JobStatus
-	result := db.First(&js, "name = ?", c.Param("jobtype"))
+	result := models.GetDB().First(&js, "name = ?", c.Param("jobtype"))
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			c.IndentedJSON(http.StatusNotFound, gin.H{"status": "error", "message": "unknown job type"})
-			return
+			return js, models.Scanner{}, false
 		}
 		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
-		return
+		return js, models.Scanner{}, false
+	}
+
+	scanner, ok := touchScanner(c, scannerName(c))
+	if !ok {
+		return js, scanner, false
 	}
+	if !scanner.Supports(js.Name) {
+		c.IndentedJSON(http.StatusForbidden, gin.H{"status": "error", "message": "scanner " + scanner.Name + " is not registered for job type " + js.Name})
+		return js, scanner, false
+	}
+	return js, scanner, true
+}
 
-	job, err := js.GetNextJob()
+// maxJobWaitSeconds caps the wait parameter of NewJob, to stay under
+// typical proxy timeouts
+const maxJobWaitSeconds = 300
+
+// handOutJob claims a queued job for the scanner or creates one for the
+// next due team
+func handOutJob(js *models.JobStatus, scanner *models.Scanner) (models.Job, error) {
+	if err := models.CheckQueuePaused(); err != nil {
+		return models.Job{}, err
+	}
+
+	// Ad hoc scans, then teams requeued after a lost lease and queued chunks, go first
+	if job, ok := models.ClaimQueuedJob(js.Name, scanner); ok {
+		job.Profile = js.ProfileFor(job)
+		models.PublishJobState(job)
+		return job, nil
+	}
+
+	job, err := js.GetNextJob(scanner)
 	if err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"status": "error", "message": err.Error()})
-		return
+		return job, err
 	}
 
 	job.StartedAt = time.Now()
 	job.Status = "running"
-	db.Create(&job)
+	job.Scanner = scanner.Name
+	job.ExtendLease()
+	models.GetDB().Create(&job)
+	models.PublishJobState(job)
+	return job, nil
+}
 
-	c.IndentedJSON(http.StatusOK, job)
+// canWaitFor reports whether a long-polling scanner should keep waiting
+// after err, rather than being told at once
+func canWaitFor(err error) bool {
+	var noWork *models.NoWorkError
+	return errors.As(err, &noWork) || errors.Is(err, models.ErrNoTeams) || errors.Is(err, models.ErrNoRoutedTeams)
+}
+
+// writeNoWork answers a job request that has nothing to hand out, with a
+// Retry-After hint when the error carries one
+func writeNoWork(c *gin.Context, err error) {
+	var noWork *models.NoWorkError
+	if errors.As(err, &noWork) {
+		c.Header("Retry-After", strconv.Itoa(noWork.RetrySeconds()))
+		c.IndentedJSON(http.StatusNotFound, gin.H{"status": "error", "message": err.Error(), "retry_after": noWork.RetrySeconds()})
+		return
+	}
+	c.IndentedJSON(http.StatusNotFound, gin.H{"status": "error", "message": err.Error()})
+}
+
+// Heartbeat godoc
+// @Summary Extend a job lease
+// @Description Scanners call this periodically while working on a job to keep their lease. A 409 means the lease was lost
+// @Description (the job was reaped or cancelled) and the scanner should stop. Only the scanner holding the job may extend it (403 otherwise).
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Param jid path string true "Job ID"
+// @Success 200 {object} map[string]interface{}
+// @Router /jobs/{jid}/heartbeat [post]
+func (j JobController) Heartbeat(c *gin.Context) {
+	db := models.GetDB()
+	var job models.Job
+
+	if err := db.First(&job, "j_id = ?", c.Param("jid")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"status": "error", "message": "job not found"})
+			return
+		}
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	scanner, ok := touchScanner(c, scannerName(c))
+	if !ok {
+		return
+	}
+	if !holdsJob(c, job, scanner.Name) {
+		return
+	}
+	if job.Status != "running" {
+		c.IndentedJSON(http.StatusConflict, gin.H{"status": "error", "message": "job is " + job.Status + ", lease cannot be extended"})
+		return
+	}
+
+	job.ExtendLease()
+	result := db.Model(&job).Updates(map[string]interface{}{
+		"lease_expires_at": job.LeaseExpiresAt,
+		"last_heartbeat":   job.LastHeartbeat,
+	})
+	if result.Error != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"status": "success", "lease_expires_at": job.LeaseExpiresAt})
 }
 
 // GetJobs godoc
 // @Summary Get all jobs
-// @Description Get list of all jobs with optional filtering
+// @Description Get a page of jobs with optional filtering. X-Total-Count gives the number of jobs matching the filters
+// @Description and X-Next-Cursor, when present, the cursor for the next page.
 // @Tags jobs
 // @Accept json
 // @Produce json
-// @Param status query string false "Filter by status"
-// @Param limit query int false "Limit results (default 50)"
+// @Param status query string false "Filter by status, comma separated for several"
+// @Param team_id query string false "Filter by team ID"
+// @Param type query string false "Filter by job type"
+// @Param scanner query string false "Filter by scanner name"
+// @Param since query string false "Only jobs created at or after this time (RFC3339)"
+// @Param until query string false "Only jobs created before this time (RFC3339)"
+// @Param sort query string false "Sort by created, started or completed (default created)"
+// @Param order query string false "asc or desc (default desc)"
+// @Param limit query int false "Limit results (default 50, max 1000)"
+// @Param cursor query string false "X-Next-Cursor from the previous page"
 // @Success 200 {array} models.Job
 // @Router /jobs [get]
 func (j JobController) GetJobs(c *gin.Context) {
-	db := models.GetDB()
-	var jobs []models.Job
-
-	query := db.Order("created_at DESC")
-
-	if status := c.Query("status"); status != "" {
-		query = query.Where("status = ?", status)
+	filter := models.JobFilter{
+		TeamID:  c.Query("team_id"),
+		Type:    c.Query("type"),
+		Scanner: c.Query("scanner"),
+	}
+	for _, param := range c.QueryArray("status") {
+		for _, status := range strings.Split(param, ",") {
+			if status = strings.TrimSpace(status); status != "" {
+				filter.Statuses = append(filter.Statuses, status)
+			}
+		}
+	}
+	for name, dest := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if v := c.Query(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "invalid " + name + " time, expected RFC3339"})
+				return
+			}
+			*dest = t
+		}
 	}
 
-	limit := 50
+	page := models.JobPage{Sort: c.DefaultQuery("sort", "created"), Limit: 50, Cursor: c.Query("cursor")}
+	if err := models.ValidateJobSort(page.Sort); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	switch c.DefaultQuery("order", "desc") {
+	case "desc":
+		page.Desc = true
+	case "asc":
+	default:
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "order must be asc or desc"})
+		return
+	}
 	if l := c.Query("limit"); l != "" {
-		// Parse limit from query
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > 1000 {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "limit must be between 1 and 1000"})
+			return
+		}
+		page.Limit = n
 	}
-	query = query.Limit(limit)
 
-	result := query.Find(&jobs)
-	if result.Error != nil {
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
+	jobs, total, next, err := models.ListJobs(filter, page)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCursor) {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
 		return
 	}
 
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	if next != "" {
+		c.Header("X-Next-Cursor", next)
+	}
 	c.IndentedJSON(http.StatusOK, jobs)
 }
 
 // UploadScan godoc
 // @Summary Upload scan results
-// @Description Upload nmap scan results for a job
+// @Description Upload scan results for a job. The body is agent JSON by default, native nmap XML when sent as application/xml,
+// @Description or raw masscan/naabu/rustscan output when the format parameter is set.
+// @Description The body is kept as a job artifact. A 409 means the job is no longer running (it was reaped, failed or
+// @Description cancelled) and the results were not accepted. Only the scanner holding the job may upload to it (403 otherwise).
 // @Tags jobs
-// @Accept json
+// @Accept json,xml,plain
 // @Produce json
 // @Param jid path string true "Job ID"
+// @Param format query string false "Upload format: json, nmap-xml, masscan-json, masscan-list, naabu, rustscan"
 // @Param scan body models.Scan true "Scan data"
 // @Success 200 {object} map[string]interface{}
 // @Router /jobs/nmap/{jid} [post]
 func (j JobController) UploadScan(c *gin.Context) {
-	var scan models.Scan
-	if err := c.ShouldBindJSON(&scan); err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "invalid scan data: " + err.Error()})
-		return
+	format := c.Query("format")
+	if format == "" {
+		switch c.ContentType() {
+		case "application/xml", "text/xml":
+			format = models.ScanFormatNmapXML
+		default:
+			format = models.ScanFormatJSON
+		}
 	}
 
-	// Debug logging
-	totalScriptsInRequest := 0
-	for _, h := range scan.Hosts {
-		for _, p := range h.Ports {
-			totalScriptsInRequest += len(p.Scripts)
-		}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "unable to read request body"})
+		return
 	}
-	fmt.Printf("[DEBUG] Received scan: %d hosts, scripts in request: %d\n", len(scan.Hosts), totalScriptsInRequest)
 
 	db := models.GetDB()
 	jid := c.Param("jid")
@@ -142,140 +370,404 @@ func (j JobController) UploadScan(c *gin.Context) {
 		return
 	}
 
-	// Start transaction for atomic update
-	tx := db.Begin()
+	if !holdsJob(c, job, scannerName(c)) {
+		return
+	}
+	// A job that was reaped, failed or cancelled may already have a retry
+	// running, so late results are refused
+	if job.Status != "running" {
+		c.IndentedJSON(http.StatusConflict, gin.H{"status": "error", "message": "job is " + job.Status + ", results not accepted"})
+		return
+	}
+
+	// Keep the raw upload, even if it does not parse, so it can be
+	// downloaded or re-ingested after a parser fix. A full disk should not
+	// cost us the results, so this only warns.
+	artifact := models.MakeArtifact(job.JID, models.ArtifactScan, models.ScanArtifactName(format), body)
+	artifact.Format = format
+	artifact.ContentType = c.ContentType()
+	artifact.UploadedBy = scannerName(c)
+	if err := models.StoreArtifact(&artifact, body); err != nil {
+		fmt.Printf("Warning: unable to store upload for job %s as an artifact: %v\n", job.JID, err)
+	}
+
+	scan, err := models.ParseScan(format, body)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "invalid scan data: " + err.Error()})
+		return
+	}
 
-	// FIX: Instead of deleting all hosts, update existing ones and add new ones
-	// This preserves historical data and prevents data loss
+	ingestJobScan(c, job, &scan, ingestUpload)
+}
 
-	// Get existing hosts for this team
-	var existingHosts []models.Host
-	tx.Where("team_id = ?", job.TID).Find(&existingHosts)
+// ingestMode says how ingestJobScan treats a scan
+type ingestMode int
+
+const (
+	// ingestUpload is a scanner's upload: the job is completed and the scan
+	// recorded in history
+	ingestUpload ingestMode = iota
+	// ingestLatest re-ingests the latest job of its type and range, which
+	// still describes the team, so it is merged in full
+	ingestLatest
+	// ingestStale re-ingests an older job: its ports are added, but later
+	// scans decide what is closed or offline
+	ingestStale
+)
 
-	// Create a map of existing hosts by IP for quick lookup
-	existingHostMap := make(map[string]*models.Host)
-	for i := range existingHosts {
-		existingHostMap[existingHosts[i].IP] = &existingHosts[i]
+// ingestJobScan merges a job's results into its team and writes the
+// response. An upload completes the job; re-ingesting an earlier upload
+// keeps the job's status and leaves scan history alone, since no new scan
+// ran.
+func ingestJobScan(c *gin.Context, job models.Job, scan *models.Scan, mode ingestMode) {
+	db := models.GetDB()
+	newScan := mode == ingestUpload
+	if mode == ingestStale {
+		scan.PortsOnly = true
 	}
 
-	hostsProcessed := 0
-	portsProcessed := 0
-	scriptsProcessed := 0
+	var team models.Team
+	if err := db.First(&team, "t_id = ?", job.TID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"status": "error", "message": "team not found for job"})
+			return
+		}
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
 
-	for _, scanHost := range scan.Hosts {
-		var host *models.Host
+	// Start transaction for atomic update; its events go out once it commits
+	txdb, events := models.BufferEvents(db)
+	tx := txdb.Begin()
 
-		if existing, found := existingHostMap[scanHost.IP]; found {
-			// Update existing host
-			host = existing
-			host.Hostname = scanHost.Hostname
-			host.OS = scanHost.OS
-			host.LastSeen = time.Now()
-			host.Status = "online"
+	// Complete the job only if it is still running, in case it was reaped or
+	// cancelled since the upload started
+	if newScan {
+		result := tx.Model(&models.Job{}).Where("id = ? AND status = ?", job.ID, "running").Update("status", "complete")
+		if result.Error != nil {
+			tx.Rollback()
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
+			return
+		}
+		if result.RowsAffected == 0 {
+			tx.Rollback()
+			c.IndentedJSON(http.StatusConflict, gin.H{"status": "error", "message": "job is no longer running, results not accepted"})
+			return
+		}
+	}
 
-			// Delete old ports (and their scripts via CASCADE) and add new ones
-			tx.Where("host_id = ?", host.ID).Delete(&models.Port{})
-		} else {
-			// Create new host
-			newHost := models.Host{
-				IP:       scanHost.IP,
-				Hostname: scanHost.Hostname,
-				OS:       scanHost.OS,
-				TeamID:   job.TID,
-				LastSeen: time.Now(),
-				Status:   "online",
-			}
-			if err := tx.Create(&newHost).Error; err != nil {
-				tx.Rollback()
-				c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
-				return
-			}
-			host = &newHost
-		}
-
-		// Add ports
-		for _, scanPort := range scanHost.Ports {
-			// Create port model from scan data
-			dbPort := models.Port{
-				Number:   scanPort.Number,
-				State:    scanPort.State,
-				Protocol: scanPort.Protocol,
-				Service:  scanPort.Service,
-				Version:  scanPort.Version,
-				HostID:   host.ID,
-			}
-			
-			if err := tx.Create(&dbPort).Error; err != nil {
-				tx.Rollback()
-				c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
-				return
-			}
-			
-			// Save script results if present
-			for _, scanScript := range scanPort.Scripts {
-				if scanScript.Name != "" && scanScript.Output != "" {
-					dbScript := models.ScriptResult{
-						PortID: dbPort.ID,
-						Name:   scanScript.Name,
-						Output: scanScript.Output,
-					}
-					if err := tx.Create(&dbScript).Error; err != nil {
-						// Log but don't fail on script save errors
-						fmt.Printf("Warning: failed to save script result for %s: %v\n", scanScript.Name, err)
-					} else {
-						scriptsProcessed++
-					}
+	// Hosts outside the team's range are rejected or rerouted to their own team
+	scans, rejected, rerouted, err := splitScanByScope(tx, team, scan)
+	if err != nil {
+		tx.Rollback()
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	// Only close ports the job's profile covered
+	scanned, err := jobCoverage(tx, job)
+	if err != nil {
+		tx.Rollback()
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	res, err := mergeScan(tx, job.TID, job.JID, scans[job.TID], scanned)
+	if err != nil {
+		tx.Rollback()
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	for tid, teamScan := range scans {
+		if tid == job.TID {
+			continue
+		}
+		rerouteRes, err := mergeScan(tx, tid, job.JID, teamScan, scanned)
+		if err != nil {
+			tx.Rollback()
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+			return
+		}
+		res.Hosts += rerouteRes.Hosts
+		res.Ports += rerouteRes.Ports
+		res.Scripts += rerouteRes.Scripts
+	}
+
+	// Mark hosts not seen in this scan as potentially offline. Only hosts
+	// inside the job's targets can be marked, so partial scans leave the
+	// rest of the team alone.
+	newPorts, missingPorts := 0, 0
+	scope, err := models.ParseIPSet(job.IPRange)
+	if err != nil {
+		fmt.Printf("Warning: job %s has unparseable IP range %q, not marking hosts offline: %v\n", job.JID, job.IPRange, err)
+	} else {
+		for _, host := range res.Remaining {
+			if mode != ingestStale && scope.Contains(host.IP) {
+				wasOnline := host.Status != "offline"
+				host.Status = "offline"
+				tx.Save(host)
+				if wasOnline {
+					models.RaiseEvent(tx, models.EventHostOffline, host.TeamID, hostEvent(host, job.JID))
 				}
 			}
-			
-			portsProcessed++
 		}
 
-		if err := tx.Save(host).Error; err != nil {
+		// Mark ports against the team's baseline and measure drift
+		newPorts, missingPorts, err = applyBaselines(tx, job.TID, scope)
+		if err != nil {
 			tx.Rollback()
 			c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
 			return
 		}
-
-		hostsProcessed++
-		delete(existingHostMap, scanHost.IP)
-	}
-
-	// Mark hosts not seen in this scan as potentially offline
-	for _, host := range existingHostMap {
-		host.Status = "offline"
-		tx.Save(host)
 	}
 
 	// Update job status
-	job.Status = "complete"
-	job.CompletedAt = time.Now()
-	job.HostsFound = hostsProcessed
-	job.PortsFound = portsProcessed
+	if newScan {
+		job.Status = "complete"
+		job.CompletedAt = time.Now()
+		job.Percent = 100
+	}
+	job.HostsFound = res.Hosts
+	job.PortsFound = res.Ports
+	job.NewPorts = newPorts
+	job.MissingPorts = missingPorts
 	tx.Save(&job)
+	models.RaiseEvent(tx, models.EventJobState, job.TID, models.MakeJobEvent(job))
 
-	// Record scan history
-	history := models.ScanHistory{
-		TeamID:    job.TID,
-		ScanTime:  time.Now(),
-		HostCount: hostsProcessed,
-		PortCount: portsProcessed,
+	// Record scan history, once every chunk of a split scan is in
+	if newScan {
+		if err := models.RecordScanCycle(tx, job); err != nil {
+			tx.Rollback()
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+			return
+		}
 	}
-	tx.Create(&history)
 
 	if err := tx.Commit().Error; err != nil {
 		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
 		return
 	}
+	events.Publish()
+
+	// Teams that received rerouted hosts get their ports re-marked too
+	for tid := range scans {
+		var other models.Team
+		if tid != job.TID && db.First(&other, "t_id = ?", tid).Error == nil {
+			refreshTeamBaseline(other)
+		}
+	}
 
 	c.IndentedJSON(http.StatusOK, gin.H{
 		"status":            "success",
-		"hosts_processed":   hostsProcessed,
-		"ports_processed":   portsProcessed,
-		"scripts_processed": scriptsProcessed,
+		"hosts_processed":   res.Hosts,
+		"ports_processed":   res.Ports,
+		"scripts_processed": res.Scripts,
+		"new_ports":         newPorts,
+		"missing_ports":     missingPorts,
+		"rejected_hosts":    rejected,
+		"rerouted_hosts":    rerouted,
 	})
 }
 
+// FailJob godoc
+// @Summary Report a failed job
+// @Description Scanners call this when a scan could not be completed (nmap crashed, timed out, permission error...).
+// @Description The job is marked failed with the error, exit code and stderr tail, and requeued up to JOB_MAX_RETRIES times.
+// @Description Only the scanner holding the job may fail it (403 otherwise).
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Param jid path string true "Job ID"
+// @Param failure body models.JobFailureRequest true "Failure details"
+// @Success 200 {object} map[string]interface{}
+// @Router /jobs/{jid}/fail [post]
+func (j JobController) FailJob(c *gin.Context) {
+	var req models.JobFailureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	var job models.Job
+	if err := db.First(&job, "j_id = ?", c.Param("jid")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"status": "error", "message": "job not found"})
+			return
+		}
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	if !holdsJob(c, job, scannerName(c)) {
+		return
+	}
+
+	job.ExitCode = req.ExitCode
+	job.Stderr = req.Stderr
+	retry, err := models.FailJob(&job, req.Error)
+	if err != nil {
+		if errors.Is(err, models.ErrJobNotRunning) {
+			c.IndentedJSON(http.StatusConflict, gin.H{"status": "error", "message": "job is " + job.Status + ", cannot report failure"})
+			return
+		}
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	resp := gin.H{"status": "success", "requeued": retry != nil}
+	if retry != nil {
+		resp["retry_jid"] = retry.JID
+		resp["retries"] = retry.Retries
+	}
+	c.IndentedJSON(http.StatusOK, resp)
+}
+
+// GetJobStats godoc
+// @Summary Get job failure rates
+// @Description Get the failure rate of finished jobs per team and per scanner
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Param hours query int false "Only count jobs created in the last N hours (default all)"
+// @Success 200 {object} map[string]interface{}
+// @Router /jobs/stats [get]
+func (j JobController) GetJobStats(c *gin.Context) {
+	var since time.Time
+	if h := c.Query("hours"); h != "" {
+		hours, err := strconv.Atoi(h)
+		if err != nil || hours <= 0 {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "hours must be a positive integer"})
+			return
+		}
+		since = time.Now().Add(-time.Duration(hours) * time.Hour)
+	}
+
+	teams, err := models.JobFailureRates("t_id", since)
+	if err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	scanners, err := models.JobFailureRates("scanner", since)
+	if err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"max_retries": models.JobMaxRetries(),
+		"teams":       teams,
+		"scanners":    scanners,
+	})
+}
+
+// CreateAdHocJob godoc
+// @Summary Queue an ad hoc scan
+// @Description Queue an on-demand job against a team, a single host or a list of ports. Ad hoc jobs are handed out by
+// @Description /jobs/{jobtype}/next ahead of scheduled work and do not affect team schedules or scan history.
+// @Description When only host_ip is given, the team is the one whose IP range contains it.
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Param job body models.AdHocJobRequest true "Ad hoc job"
+// @Success 201 {object} models.Job
+// @Router /jobs [post]
+func (j JobController) CreateAdHocJob(c *gin.Context) {
+	var req models.AdHocJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if req.TeamID == "" && req.HostIP == "" {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "team_id or host_ip is required"})
+		return
+	}
+	if req.HostIP != "" && net.ParseIP(req.HostIP) == nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "invalid host IP: " + req.HostIP})
+		return
+	}
+	if req.Ports != "" {
+		if _, err := models.ParsePortList(req.Ports); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+			return
+		}
+	}
+
+	db := models.GetDB()
+
+	var js models.JobStatus
+	if err := db.First(&js, "name = ?", req.JobType).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "unknown job type"})
+			return
+		}
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	var team models.Team
+	if req.TeamID != "" {
+		if err := db.First(&team, "t_id = ?", req.TeamID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.IndentedJSON(http.StatusNotFound, gin.H{"status": "error", "message": "team not found"})
+				return
+			}
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+			return
+		}
+		if req.HostIP != "" {
+			if scope, err := models.ParseIPSet(team.IPRange); err != nil || !scope.Contains(req.HostIP) {
+				c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "host " + req.HostIP + " is outside team IP range " + team.IPRange})
+				return
+			}
+		}
+	} else {
+		var teams []models.Team
+		if err := db.Order("name ASC").Find(&teams).Error; err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+			return
+		}
+		found := false
+		for _, t := range teams {
+			if scope, err := models.ParseIPSet(t.IPRange); err == nil && scope.Contains(req.HostIP) {
+				team, found = t, true
+				break
+			}
+		}
+		if !found {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "host " + req.HostIP + " is outside the IP range of every team"})
+			return
+		}
+	}
+
+	target := team.IPRange
+	if req.HostIP != "" {
+		exclusions, err := models.TeamExclusions(db, team.TID)
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+			return
+		}
+		if exclusions.Contains(req.HostIP) {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "host " + req.HostIP + " is excluded from scanning"})
+			return
+		}
+		target = req.HostIP
+	}
+	job := models.MakeJob(js.Name, target, team.TID, team.Name)
+	job.AdHoc = true
+	job.Ports = strings.ReplaceAll(req.Ports, " ", "")
+	job.RequestedBy = c.GetString("user")
+	if err := db.Create(&job).Error; err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	models.PublishJobState(job)
+	models.NotifyWork()
+
+	c.IndentedJSON(http.StatusCreated, job)
+}
+
 // CancelJob godoc
 // @Summary Cancel a job
 // @Description Cancel a running or queued job
@@ -306,6 +798,12 @@ func (j JobController) CancelJob(c *gin.Context) {
 	job.Status = "cancelled"
 	job.CompletedAt = time.Now()
 	db.Save(&job)
+	models.PublishJobState(job)
+
+	// Cancelling the last outstanding chunk finishes its cycle
+	if err := models.RecordScanCycle(db, job); err != nil {
+		fmt.Printf("Warning: failed to record scan cycle %s: %v\n", job.CycleID, err)
+	}
 
 	c.IndentedJSON(http.StatusOK, gin.H{"status": "success", "message": "job cancelled"})
 }
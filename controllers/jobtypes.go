@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/brian-l-johnson/Redteam-Dashboard-go/v2/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type JobTypeController struct{}
+
+// checkTeamChunks checks that every team's range can be split by chunkSize
+func checkTeamChunks(chunkSize string) error {
+	var teams []models.Team
+	if err := models.GetDB().Find(&teams).Error; err != nil {
+		return err
+	}
+	for _, team := range teams {
+		if err := models.CheckChunks(team.IPRange, chunkSize); err != nil {
+			return fmt.Errorf("team %s: %v", team.Name, err)
+		}
+	}
+	return nil
+}
+
+// CreateJobType godoc
+// @Summary Create job type
+// @Description Register a new job type with its scan profile. Scanners request it with /jobs/{name}/next.
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Param jobtype body models.JobTypeRequest true "Job type data"
+// @Success 201 {object} models.JobStatus
+// @Router /jobtypes [post]
+func (jt JobTypeController) CreateJobType(c *gin.Context) {
+	var req models.JobTypeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if err := models.ValidateJobTypeName(req.Name); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if _, err := models.ParseChunkSize(req.ChunkSize); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if err := checkTeamChunks(req.ChunkSize); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if err := models.ValidateStrategy(req.Strategy); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if req.Strategy == "" {
+		req.Strategy = models.StrategyRoundRobin
+	}
+	if err := req.ScanProfile.Validate(); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+
+	// Check if job type already exists
+	var existing models.JobStatus
+	result := db.First(&existing, "name = ?", req.Name)
+	if result.Error == nil {
+		c.IndentedJSON(http.StatusConflict, gin.H{"status": "error", "message": "job type already exists"})
+		return
+	}
+
+	js := models.JobStatus{Name: req.Name, ChunkSize: req.ChunkSize, Strategy: req.Strategy, ScanProfile: req.ScanProfile}
+	result = db.Create(&js)
+	if result.Error != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
+		return
+	}
+
+	js.Argv = js.CommandArgs()
+	c.IndentedJSON(http.StatusCreated, js)
+}
+
+// UpdateJobType godoc
+// @Summary Update job type
+// @Description Replace a job type's scan profile. Jobs already handed out keep the profile they were given.
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Param name path string true "Job type name"
+// @Param jobtype body models.JobTypeRequest true "Job type data (name is ignored)"
+// @Success 200 {object} models.JobStatus
+// @Router /jobtypes/{name} [put]
+func (jt JobTypeController) UpdateJobType(c *gin.Context) {
+	db := models.GetDB()
+	var js models.JobStatus
+
+	result := db.First(&js, "name = ?", c.Param("name"))
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"status": "error", "message": "unknown job type"})
+			return
+		}
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
+		return
+	}
+
+	var req models.JobTypeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if _, err := models.ParseChunkSize(req.ChunkSize); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if err := checkTeamChunks(req.ChunkSize); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if err := models.ValidateStrategy(req.Strategy); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if req.Strategy == "" {
+		req.Strategy = models.StrategyRoundRobin
+	}
+	if err := req.ScanProfile.Validate(); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	js.ChunkSize = req.ChunkSize
+	js.Strategy = req.Strategy
+	js.ScanProfile = req.ScanProfile
+	// The rotation is moved on by job handout, so leave it alone
+	result = db.Omit("job_index").Save(&js)
+	if result.Error != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
+		return
+	}
+
+	js.Argv = js.CommandArgs()
+	c.IndentedJSON(http.StatusOK, js)
+}
+
+// DeleteJobType godoc
+// @Summary Delete job type
+// @Description Remove a job type. Finished jobs of that type are kept. A 409 means the type still has queued or running
+// @Description jobs, which must finish or be cancelled first.
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Param name path string true "Job type name"
+// @Success 200 {object} map[string]string
+// @Router /jobtypes/{name} [delete]
+func (jt JobTypeController) DeleteJobType(c *gin.Context) {
+	db := models.GetDB()
+	var js models.JobStatus
+
+	result := db.First(&js, "name = ?", c.Param("name"))
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"status": "error", "message": "unknown job type"})
+			return
+		}
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
+		return
+	}
+
+	// Queued and running jobs still need their type to be handed out,
+	// reaped and retried, so they must finish or be cancelled first
+	tx := db.Begin()
+	var active int64
+	if err := tx.Model(&models.Job{}).Where("type = ? AND status IN ?", js.Name, []string{"queued", "running"}).Count(&active).Error; err != nil {
+		tx.Rollback()
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if active > 0 {
+		tx.Rollback()
+		c.IndentedJSON(http.StatusConflict, gin.H{"status": "error", "message": fmt.Sprintf("job type has %d queued or running jobs, cancel them first", active)})
+		return
+	}
+
+	// Hard delete so the name can be registered again
+	if err := tx.Unscoped().Delete(&js).Error; err != nil {
+		tx.Rollback()
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"status": "success", "message": "job type deleted"})
+}
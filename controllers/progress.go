@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/brian-l-johnson/Redteam-Dashboard-go/v2/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ReportProgress godoc
+// @Summary Report job progress
+// @Description Scanners call this while a job runs to report percent complete, hosts finished, ETA and new log lines.
+// @Description Results for hosts that are finished can be sent in hosts (agent JSON format) and are merged right away;
+// @Description the final upload still completes the job and marks hosts that were not seen as offline.
+// @Description A report also extends the job's lease. Only the scanner holding the job may report on it (403 otherwise).
+// @Description A 409 means the job is no longer running and the scanner should stop.
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Param jid path string true "Job ID"
+// @Param progress body models.JobProgressRequest true "Progress"
+// @Success 200 {object} map[string]interface{}
+// @Router /jobs/{jid}/progress [post]
+func (j JobController) ReportProgress(c *gin.Context) {
+	var req models.JobProgressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	var job models.Job
+	if err := db.First(&job, "j_id = ?", c.Param("jid")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"status": "error", "message": "job not found"})
+			return
+		}
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	scanner, ok := touchScanner(c, scannerName(c))
+	if !ok {
+		return
+	}
+	if !holdsJob(c, job, scanner.Name) {
+		return
+	}
+	if job.Status != "running" {
+		c.IndentedJSON(http.StatusConflict, gin.H{"status": "error", "message": "job is " + job.Status})
+		return
+	}
+
+	var team models.Team
+	if len(req.Hosts) > 0 {
+		if err := db.First(&team, "t_id = ?", job.TID).Error; err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "team not found for job"})
+			return
+		}
+	}
+
+	txdb, events := models.BufferEvents(db)
+	tx := txdb.Begin()
+	if err := models.RecordProgress(tx, &job, req); err != nil {
+		tx.Rollback()
+		if errors.Is(err, models.ErrJobNotRunning) {
+			c.IndentedJSON(http.StatusConflict, gin.H{"status": "error", "message": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	// Finished hosts are committed now so they show up before the scan ends
+	res := ingestResult{}
+	var rejected []rejectedHost
+	var rerouted []reroutedHost
+	if len(req.Hosts) > 0 {
+		scan := models.Scan{Hosts: req.Hosts}
+		var scans map[string]*models.Scan
+		var err error
+		scans, rejected, rerouted, err = splitScanByScope(tx, team, &scan)
+		if err != nil {
+			tx.Rollback()
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+			return
+		}
+
+		scanned, err := jobCoverage(tx, job)
+		if err != nil {
+			tx.Rollback()
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+			return
+		}
+		for tid, teamScan := range scans {
+			teamRes, err := mergeScan(tx, tid, job.JID, teamScan, scanned)
+			if err != nil {
+				tx.Rollback()
+				c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+				return
+			}
+			res.Hosts += teamRes.Hosts
+			res.Ports += teamRes.Ports
+		}
+	}
+
+	models.RaiseEvent(tx, models.EventJobProgress, job.TID, models.MakeJobEvent(job))
+	if err := tx.Commit().Error; err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	events.Publish()
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status":           "success",
+		"lease_expires_at": job.LeaseExpiresAt,
+		"hosts_processed":  res.Hosts,
+		"ports_processed":  res.Ports,
+		"rejected_hosts":   rejected,
+		"rerouted_hosts":   rerouted,
+	})
+}
+
+// GetProgress godoc
+// @Summary Get job progress
+// @Description Get the latest progress and log lines of one job, or of every running job when jid is not given
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Param jid query string false "Job ID"
+// @Param lines query int false "Log lines per job (default 20, max 500)"
+// @Success 200 {array} models.JobProgress
+// @Router /jobs/progress [get]
+func (j JobController) GetProgress(c *gin.Context) {
+	lines := 20
+	if l := c.Query("lines"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 0 || n > 500 {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "lines must be between 0 and 500"})
+			return
+		}
+		lines = n
+	}
+
+	db := models.GetDB()
+	var jobs []models.Job
+	query := db.Order("id ASC")
+	if jid := c.Query("jid"); jid != "" {
+		query = query.Where("j_id = ?", jid)
+	} else {
+		query = query.Where("status = ?", "running")
+	}
+	if err := query.Find(&jobs).Error; err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	progress, err := models.LoadJobProgress(jobs, lines)
+	if err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, progress)
+}
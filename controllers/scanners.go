@@ -0,0 +1,216 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/brian-l-johnson/Redteam-Dashboard-go/v2/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ScannerController struct{}
+
+// scannerHeader lets agents sharing one scanner account tell themselves apart
+const scannerHeader = "X-Scanner-Name"
+
+// scannerName identifies the calling agent from the X-Scanner-Name header,
+// falling back to the account name
+func scannerName(c *gin.Context) string {
+	if name := c.GetHeader(scannerHeader); name != "" {
+		return name
+	}
+	return c.GetString("user")
+}
+
+// touchScanner records that the named agent was heard from. On failure the
+// error response has been written.
+func touchScanner(c *gin.Context, name string) (models.Scanner, bool) {
+	scanner, err := models.TouchScanner(name, c.GetString("user"), c.ClientIP())
+	if err != nil {
+		if errors.Is(err, models.ErrScannerOwner) {
+			c.IndentedJSON(http.StatusForbidden, gin.H{"status": "error", "message": err.Error()})
+			return scanner, false
+		}
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return scanner, false
+	}
+	return scanner, true
+}
+
+// holdsJob checks that the named agent is the one the job was handed to. On
+// failure a 403 has been written.
+func holdsJob(c *gin.Context, job models.Job, name string) bool {
+	if job.Scanner != "" && job.Scanner != name {
+		c.IndentedJSON(http.StatusForbidden, gin.H{"status": "error", "message": "job is held by scanner " + job.Scanner})
+		return false
+	}
+	return true
+}
+
+// GetScanners godoc
+// @Summary List scanners
+// @Description List registered scanner agents with their online state, current job and throughput over the last hours (default 24)
+// @Tags scanners
+// @Accept json
+// @Produce json
+// @Param hours query int false "Throughput window in hours"
+// @Success 200 {array} models.Scanner
+// @Router /scanners [get]
+func (s ScannerController) GetScanners(c *gin.Context) {
+	hours := 24
+	if h := c.Query("hours"); h != "" {
+		var err error
+		if hours, err = strconv.Atoi(h); err != nil || hours <= 0 {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "hours must be a positive integer"})
+			return
+		}
+	}
+
+	db := models.GetDB()
+	var scanners []models.Scanner
+	if err := db.Order("name ASC").Find(&scanners).Error; err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if err := models.LoadScannerState(scanners, time.Now().Add(-time.Duration(hours)*time.Hour)); err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, scanners)
+}
+
+// RegisterScanner godoc
+// @Summary Register a scanner agent
+// @Description Agents call this on start to record their name, version and supported job types. The source IP is taken
+// @Description from the request. Registering again updates the entry. Job types may be left empty to accept every type.
+// @Tags scanners
+// @Accept json
+// @Produce json
+// @Param scanner body models.ScannerRegistration true "Scanner details"
+// @Success 200 {object} models.Scanner
+// @Router /scanners/register [post]
+func (s ScannerController) RegisterScanner(c *gin.Context) {
+	var req models.ScannerRegistration
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if req.Name == "" {
+		req.Name = scannerName(c)
+	}
+
+	db := models.GetDB()
+	for _, jobType := range req.JobTypes {
+		var js models.JobStatus
+		if err := db.First(&js, "name = ?", jobType).Error; err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "unknown job type: " + jobType})
+			return
+		}
+	}
+
+	scanner, ok := touchScanner(c, req.Name)
+	if !ok {
+		return
+	}
+	scanner.Version = req.Version
+	scanner.JobTypes = req.JobTypes
+	if err := db.Model(&scanner).Select("version", "job_types").Updates(&scanner).Error; err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, scanner)
+}
+
+// ScannerHeartbeat godoc
+// @Summary Scanner heartbeat
+// @Description Idle agents call this periodically so they are shown as online. Job heartbeats and job requests count too.
+// @Tags scanners
+// @Accept json
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /scanners/heartbeat [post]
+func (s ScannerController) ScannerHeartbeat(c *gin.Context) {
+	scanner, ok := touchScanner(c, scannerName(c))
+	if !ok {
+		return
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{"status": "success", "name": scanner.Name, "last_heartbeat": scanner.LastHeartbeat})
+}
+
+// SetScannerTags godoc
+// @Summary Set scanner tags
+// @Description Set the tags of a scanner agent. Teams with scanner tags are only handed to scanners carrying one of them.
+// @Tags scanners
+// @Accept json
+// @Produce json
+// @Param name path string true "Scanner name"
+// @Param tags body models.ScannerTagsRequest true "Tags"
+// @Success 200 {object} models.Scanner
+// @Router /scanners/{name}/tags [put]
+func (s ScannerController) SetScannerTags(c *gin.Context) {
+	var req models.ScannerTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if err := models.ValidateScannerTags(req.Tags); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	var scanner models.Scanner
+	if err := db.First(&scanner, "name = ?", c.Param("name")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"status": "error", "message": "scanner not found"})
+			return
+		}
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	scanner.Tags = req.Tags
+	if err := db.Model(&scanner).Select("tags").Updates(&scanner).Error; err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	// A waiting scanner may now reach more teams
+	models.NotifyWork()
+
+	c.IndentedJSON(http.StatusOK, scanner)
+}
+
+// DeleteScanner godoc
+// @Summary Remove a scanner
+// @Description Forget a scanner agent. It is registered again the next time it calls in.
+// @Tags scanners
+// @Accept json
+// @Produce json
+// @Param name path string true "Scanner name"
+// @Success 200 {object} map[string]string
+// @Router /scanners/{name} [delete]
+func (s ScannerController) DeleteScanner(c *gin.Context) {
+	db := models.GetDB()
+	var scanner models.Scanner
+	if err := db.First(&scanner, "name = ?", c.Param("name")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"status": "error", "message": "scanner not found"})
+			return
+		}
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	if err := db.Unscoped().Delete(&scanner).Error; err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"status": "success", "message": "scanner removed"})
+}
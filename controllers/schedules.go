@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/brian-l-johnson/Redteam-Dashboard-go/v2/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ScheduleController struct{}
+
+// GetSchedules godoc
+// @Summary Get scan schedules
+// @Description Get scan schedules, with the next due time for team-specific ones
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Param team_id query string false "Filter by team ID"
+// @Param job_type query string false "Filter by job type"
+// @Success 200 {array} models.Schedule
+// @Router /schedules [get]
+func (s ScheduleController) GetSchedules(c *gin.Context) {
+	db := models.GetDB()
+	var schedules []models.Schedule
+
+	query := db.Order("job_type ASC, team_id ASC")
+	if tid := c.Query("team_id"); tid != "" {
+		query = query.Where("team_id = ?", tid)
+	}
+	if jobType := c.Query("job_type"); jobType != "" {
+		query = query.Where("job_type = ?", jobType)
+	}
+
+	result := query.Find(&schedules)
+	if result.Error != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
+		return
+	}
+	if err := models.ScheduleNextDue(schedules); err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, schedules)
+}
+
+// CreateSchedule godoc
+// @Summary Create scan schedule
+// @Description Schedule a job type for a team (or, without team_id, for every team) with a cron expression or a minimum interval
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Param schedule body models.ScheduleRequest true "Schedule data"
+// @Success 201 {object} models.Schedule
+// @Router /schedules [post]
+func (s ScheduleController) CreateSchedule(c *gin.Context) {
+	var req models.ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	if err := validateScheduleTarget(db, req); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	schedule := models.MakeSchedule(req.TeamID, req.JobType)
+	schedule.Cron = req.Cron
+	schedule.IntervalSeconds = req.IntervalSeconds
+	if err := schedule.Validate(); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	// Only one schedule per team and job type
+	var existing models.Schedule
+	result := db.First(&existing, "team_id = ? AND job_type = ?", req.TeamID, req.JobType)
+	if result.Error == nil {
+		c.IndentedJSON(http.StatusConflict, gin.H{"status": "error", "message": "schedule already exists for this team and job type"})
+		return
+	}
+
+	result = db.Create(&schedule)
+	if result.Error != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusCreated, schedule)
+}
+
+// UpdateSchedule godoc
+// @Summary Update scan schedule
+// @Description Change a schedule's cron expression or interval
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Param sid path string true "Schedule ID"
+// @Param schedule body models.ScheduleRequest true "Schedule data (team_id and job_type are ignored)"
+// @Success 200 {object} models.Schedule
+// @Router /schedules/{sid} [put]
+func (s ScheduleController) UpdateSchedule(c *gin.Context) {
+	db := models.GetDB()
+	var schedule models.Schedule
+
+	result := db.First(&schedule, "s_id = ?", c.Param("sid"))
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"status": "error", "message": "schedule not found"})
+			return
+		}
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
+		return
+	}
+
+	var req models.ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	schedule.Cron = req.Cron
+	schedule.IntervalSeconds = req.IntervalSeconds
+	if err := schedule.Validate(); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	result = db.Save(&schedule)
+	if result.Error != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, schedule)
+}
+
+// DeleteSchedule godoc
+// @Summary Delete scan schedule
+// @Description Remove a schedule. Teams it covered fall back to the job type default, or are always due.
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Param sid path string true "Schedule ID"
+// @Success 200 {object} map[string]string
+// @Router /schedules/{sid} [delete]
+func (s ScheduleController) DeleteSchedule(c *gin.Context) {
+	db := models.GetDB()
+	var schedule models.Schedule
+
+	result := db.First(&schedule, "s_id = ?", c.Param("sid"))
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"status": "error", "message": "schedule not found"})
+			return
+		}
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
+		return
+	}
+
+	// Hard delete so the team and job type can be scheduled again
+	result = db.Unscoped().Delete(&schedule)
+	if result.Error != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"status": "success", "message": "schedule deleted"})
+}
+
+// validateScheduleTarget checks the schedule's job type and team exist
+func validateScheduleTarget(db *gorm.DB, req models.ScheduleRequest) error {
+	var js models.JobStatus
+	if db.First(&js, "name = ?", req.JobType).Error != nil {
+		return errors.New("unknown job type")
+	}
+	if req.TeamID != "" {
+		var team models.Team
+		if db.First(&team, "t_id = ?", req.TeamID).Error != nil {
+			return errors.New("team not found")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/brian-l-johnson/Redteam-Dashboard-go/v2/models"
+)
+
+func hostIPs(scan *models.Scan) []string {
+	if scan == nil {
+		return nil
+	}
+	ips := []string{}
+	for _, host := range scan.Hosts {
+		ips = append(ips, host.IP)
+	}
+	return ips
+}
+
+func TestSplitScanByScope(t *testing.T) {
+	db := useTestDB(t)
+	team1 := createTeam(t, db, "team1", "10.0.1.0/24")
+	team2 := createTeam(t, db, "team2", "10.0.2.0/24")
+	team3 := createTeam(t, db, "team3", "10.0.3.1-20")
+	global := models.MakeExclusion("", "10.0.1.250")
+	scoring := models.MakeExclusion(team2.TID, "10.0.2.99")
+	db.Create(&global)
+	db.Create(&scoring)
+
+	scan := &models.Scan{PortsOnly: true}
+	for _, ip := range []string{"10.0.1.5", "10.0.1.250", "10.0.2.7", "10.0.2.99", "10.9.9.9", "10.0.3.1"} {
+		scan.Hosts = append(scan.Hosts, models.ScanHost{IP: ip, Status: "up"})
+	}
+
+	t.Run("reject", func(t *testing.T) {
+		t.Setenv("OUT_OF_SCOPE_HOSTS", "")
+		scans, rejected, rerouted, err := splitScanByScope(db, team1, scan)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(scans) != 1 || !reflect.DeepEqual(hostIPs(scans[team1.TID]), []string{"10.0.1.5"}) {
+			t.Errorf("team1 keeps %v of %d scans", hostIPs(scans[team1.TID]), len(scans))
+		}
+		want := []rejectedHost{
+			{IP: "10.0.1.250", Error: "excluded from scanning"},
+			{IP: "10.0.2.7", Error: "outside team IP range 10.0.1.0/24"},
+			{IP: "10.0.2.99", Error: "outside team IP range 10.0.1.0/24"},
+			{IP: "10.9.9.9", Error: "outside team IP range 10.0.1.0/24"},
+			{IP: "10.0.3.1", Error: "outside team IP range 10.0.1.0/24"},
+		}
+		if !reflect.DeepEqual(rejected, want) {
+			t.Errorf("rejected = %+v\nwant %+v", rejected, want)
+		}
+		if len(rerouted) != 0 {
+			t.Errorf("rerouted %+v in reject mode", rerouted)
+		}
+	})
+
+	t.Run("reroute", func(t *testing.T) {
+		t.Setenv("OUT_OF_SCOPE_HOSTS", "reroute")
+		scans, rejected, rerouted, err := splitScanByScope(db, team1, scan)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for tid, want := range map[string][]string{
+			team1.TID: {"10.0.1.5"},
+			team2.TID: {"10.0.2.7"},
+			team3.TID: {"10.0.3.1"},
+		} {
+			if got := hostIPs(scans[tid]); !reflect.DeepEqual(got, want) {
+				t.Errorf("team %s gets %v, want %v", tid, got, want)
+			}
+			if scans[tid] != nil && !scans[tid].PortsOnly {
+				t.Errorf("team %s scan lost the upload's ports-only flag", tid)
+			}
+		}
+
+		// Exclusions of the team a host would be rerouted to still apply
+		wantRejected := []rejectedHost{
+			{IP: "10.0.1.250", Error: "excluded from scanning"},
+			{IP: "10.0.2.99", Error: "excluded from scanning"},
+			{IP: "10.9.9.9", Error: "outside the IP range of every team"},
+		}
+		if !reflect.DeepEqual(rejected, wantRejected) {
+			t.Errorf("rejected = %+v\nwant %+v", rejected, wantRejected)
+		}
+		wantRerouted := []reroutedHost{
+			{IP: "10.0.2.7", TeamID: team2.TID, TeamName: "team2"},
+			{IP: "10.0.3.1", TeamID: team3.TID, TeamName: "team3"},
+		}
+		if !reflect.DeepEqual(rerouted, wantRerouted) {
+			t.Errorf("rerouted = %+v\nwant %+v", rerouted, wantRerouted)
+		}
+	})
+}
+
+func TestUploadReroutesHost(t *testing.T) {
+	t.Setenv("OUT_OF_SCOPE_HOSTS", "reroute")
+	db := useTestDB(t)
+	team1 := createTeam(t, db, "team1", "10.0.1.0/24")
+	team2 := createTeam(t, db, "team2", "10.0.2.0/24")
+	createHost(t, db, team2, "10.0.2.8", "online", 22)
+
+	job := models.MakeJob("nmap", team1.IPRange, team1.TID, team1.Name)
+	job.Status = "running"
+	db.Create(&job)
+	scan := models.Scan{Hosts: []models.ScanHost{
+		{IP: "10.0.1.5", Status: "up", Ports: openPorts(models.ScanPort{Number: 22})},
+		{IP: "10.0.2.7", Status: "up", Ports: openPorts(models.ScanPort{Number: 3389})},
+	}}
+
+	c, w := testContext()
+	ingestJobScan(c, job, &scan, ingestUpload)
+	if w.Code != http.StatusOK {
+		t.Fatalf("upload: %d %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Hosts    int            `json:"hosts_processed"`
+		Rerouted []reroutedHost `json:"rerouted_hosts"`
+	}
+	decodeResponse(t, w, &resp)
+	if resp.Hosts != 2 || len(resp.Rerouted) != 1 || resp.Rerouted[0].TeamID != team2.TID {
+		t.Errorf("response: %d hosts, rerouted %+v", resp.Hosts, resp.Rerouted)
+	}
+
+	// The rerouted host belongs to team2 only, and team2's other hosts are
+	// not marked offline by a scan of team1's range
+	if rerouted := findHost(t, db, team2.TID, "10.0.2.7"); len(rerouted.Ports) != 1 {
+		t.Errorf("rerouted host has %d ports, want 1", len(rerouted.Ports))
+	}
+	var count int64
+	db.Model(&models.Host{}).Where("team_id = ? AND ip = ?", team1.TID, "10.0.2.7").Count(&count)
+	if count != 0 {
+		t.Error("rerouted host was also stored for team1")
+	}
+	if other := findHost(t, db, team2.TID, "10.0.2.8"); other.Status != "online" {
+		t.Errorf("team2's other host is %s after team1's scan", other.Status)
+	}
+}
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/brian-l-johnson/Redteam-Dashboard-go/v2/models"
@@ -11,6 +12,21 @@ import (
 
 type TeamController struct{}
 
+// checkRangeChunks checks that a team range can be split by every job
+// type's chunk size
+func checkRangeChunks(iprange string) error {
+	var types []models.JobStatus
+	if err := models.GetDB().Find(&types).Error; err != nil {
+		return err
+	}
+	for _, js := range types {
+		if err := models.CheckChunks(iprange, js.ChunkSize); err != nil {
+			return fmt.Errorf("job type %s: %v", js.Name, err)
+		}
+	}
+	return nil
+}
+
 // GetTeams godoc
 // @Summary Get Teams
 // @Description Get all teams with optional host data
@@ -81,11 +97,24 @@ func (t TeamController) CreateTeam(c *gin.Context) {
 		return
 	}
 
+	if req.Weight < 0 || req.Weight > 100 {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "weight must be between 1 and 100, or 0 for the default of 1"})
+		return
+	}
+	if err := models.ValidateScannerTags(req.ScannerTags); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
 	// Validate IP range
 	if err := models.ValidateIPRange(req.IPRange); err != nil {
 		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
 		return
 	}
+	if err := checkRangeChunks(req.IPRange); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
 
 	db := models.GetDB()
 
@@ -102,12 +131,17 @@ func (t TeamController) CreateTeam(c *gin.Context) {
 	if req.Color != "" {
 		team.Color = req.Color
 	}
+	if req.Weight > 0 {
+		team.Weight = req.Weight
+	}
+	team.ScannerTags = req.ScannerTags
 
 	result = db.Create(&team)
 	if result.Error != nil {
 		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
 		return
 	}
+	models.NotifyWork()
 
 	c.IndentedJSON(http.StatusCreated, team)
 }
@@ -143,11 +177,24 @@ func (t TeamController) UpdateTeam(c *gin.Context) {
 		return
 	}
 
+	if req.Weight < 0 || req.Weight > 100 {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "weight must be between 1 and 100, or 0 to keep the current weight"})
+		return
+	}
+	if err := models.ValidateScannerTags(req.ScannerTags); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
 	// Validate IP range
 	if err := models.ValidateIPRange(req.IPRange); err != nil {
 		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
 		return
 	}
+	if err := checkRangeChunks(req.IPRange); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
 
 	// Check if new name conflicts with another team
 	if req.Name != team.Name {
@@ -165,12 +212,17 @@ func (t TeamController) UpdateTeam(c *gin.Context) {
 	if req.Color != "" {
 		team.Color = req.Color
 	}
+	if req.Weight > 0 {
+		team.Weight = req.Weight
+	}
+	team.ScannerTags = req.ScannerTags
 
 	result = db.Save(&team)
 	if result.Error != nil {
 		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
 		return
 	}
+	models.NotifyWork()
 
 	c.IndentedJSON(http.StatusOK, team)
 }
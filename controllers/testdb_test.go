@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/brian-l-johnson/Redteam-Dashboard-go/v2/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// useTestDB points the models package at a fresh, migrated database in a
+// temporary directory for the rest of the test, and returns it
+func useTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	// Store the admin first, or Init spends a second or two hashing its
+	// password at full bcrypt cost
+	pre, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := models.MakeUser("admin")
+	if err := pre.AutoMigrate(&models.User{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pre.Create(&admin).Error; err != nil {
+		t.Fatal(err)
+	}
+	if sqlDB, err := pre.DB(); err == nil {
+		sqlDB.Close()
+	}
+
+	t.Setenv("DB_PATH", path)
+	t.Setenv("GIN_MODE", "release")
+	models.Init()
+	db := models.GetDB()
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func createTeam(t *testing.T, db *gorm.DB, name string, iprange string) models.Team {
+	t.Helper()
+	team := models.MakeTeam(name, iprange)
+	if err := db.Create(&team).Error; err != nil {
+		t.Fatal(err)
+	}
+	return team
+}
+
+// createHost stores a host of the team with the given status and open TCP ports
+func createHost(t *testing.T, db *gorm.DB, team models.Team, ip string, status string, ports ...uint16) models.Host {
+	t.Helper()
+	host := models.Host{IP: ip, TeamID: team.TID, Status: status}
+	for _, number := range ports {
+		host.Ports = append(host.Ports, models.Port{Number: number, Protocol: "tcp", State: "open"})
+	}
+	if err := db.Create(&host).Error; err != nil {
+		t.Fatal(err)
+	}
+	return host
+}
+
+func findHost(t *testing.T, db *gorm.DB, teamID string, ip string) models.Host {
+	t.Helper()
+	var host models.Host
+	if err := db.Preload("Ports").First(&host, "team_id = ? AND ip = ?", teamID, ip).Error; err != nil {
+		t.Fatalf("host %s: %v", ip, err)
+	}
+	return host
+}
+
+// testContext returns a gin context that records the handler's response
+func testContext() (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/", nil)
+	return c, w
+}
+
+// decodeResponse unmarshals a recorded JSON response into v
+func decodeResponse(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("response %d is not JSON: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,255 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/brian-l-johnson/Redteam-Dashboard-go/v2/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type WindowController struct{}
+
+// GetWindows godoc
+// @Summary Get scan windows
+// @Description Get the allow and blackout windows. With team_id, only the windows that apply to that team
+// @Description (its own plus the global ones) are returned.
+// @Tags windows
+// @Accept json
+// @Produce json
+// @Param team_id query string false "Team ID"
+// @Success 200 {array} models.ScanWindow
+// @Router /windows [get]
+func (w WindowController) GetWindows(c *gin.Context) {
+	db := models.GetDB()
+	var windows []models.ScanWindow
+
+	query := db.Order("start ASC")
+	if tid := c.Query("team_id"); tid != "" {
+		query = query.Where("team_id = ? OR team_id = ?", "", tid)
+	}
+
+	result := query.Find(&windows)
+	if result.Error != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, windows)
+}
+
+// CreateWindow godoc
+// @Summary Create scan window
+// @Description Add an allow or blackout window, for one team or (without team_id) for every team. No jobs are handed out
+// @Description during a blackout. Once allow windows exist, jobs are only handed out inside one of them.
+// @Tags windows
+// @Accept json
+// @Produce json
+// @Param window body models.ScanWindowRequest true "Window data"
+// @Success 201 {object} models.ScanWindow
+// @Router /windows [post]
+func (w WindowController) CreateWindow(c *gin.Context) {
+	var req models.ScanWindowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	if req.TeamID != "" {
+		var team models.Team
+		if db.First(&team, "t_id = ?", req.TeamID).Error != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "team not found"})
+			return
+		}
+	}
+
+	window := models.MakeScanWindow(req.TeamID, req.Kind, req.Start, req.End)
+	window.Reason = req.Reason
+	if err := window.Validate(); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	result := db.Create(&window)
+	if result.Error != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusCreated, window)
+}
+
+// UpdateWindow godoc
+// @Summary Update scan window
+// @Description Change a window's kind, times or reason
+// @Tags windows
+// @Accept json
+// @Produce json
+// @Param wid path string true "Window ID"
+// @Param window body models.ScanWindowRequest true "Window data (team_id is ignored)"
+// @Success 200 {object} models.ScanWindow
+// @Router /windows/{wid} [put]
+func (w WindowController) UpdateWindow(c *gin.Context) {
+	db := models.GetDB()
+	var window models.ScanWindow
+
+	result := db.First(&window, "w_id = ?", c.Param("wid"))
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"status": "error", "message": "window not found"})
+			return
+		}
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
+		return
+	}
+
+	var req models.ScanWindowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	window.Kind = req.Kind
+	window.Start = req.Start
+	window.End = req.End
+	window.Reason = req.Reason
+	if err := window.Validate(); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	result = db.Save(&window)
+	if result.Error != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
+		return
+	}
+	models.NotifyWork()
+
+	c.IndentedJSON(http.StatusOK, window)
+}
+
+// DeleteWindow godoc
+// @Summary Delete scan window
+// @Description Remove an allow or blackout window
+// @Tags windows
+// @Accept json
+// @Produce json
+// @Param wid path string true "Window ID"
+// @Success 200 {object} map[string]string
+// @Router /windows/{wid} [delete]
+func (w WindowController) DeleteWindow(c *gin.Context) {
+	db := models.GetDB()
+	var window models.ScanWindow
+
+	result := db.First(&window, "w_id = ?", c.Param("wid"))
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"status": "error", "message": "window not found"})
+			return
+		}
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
+		return
+	}
+
+	result = db.Unscoped().Delete(&window)
+	if result.Error != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": result.Error.Error()})
+		return
+	}
+	models.NotifyWork()
+
+	c.IndentedJSON(http.StatusOK, gin.H{"status": "success", "message": "window deleted"})
+}
+
+// GetQueueState godoc
+// @Summary Get queue pause state
+// @Description Whether job handout is manually paused, with the pause and resume history (latest first, default 50 entries)
+// @Tags windows
+// @Accept json
+// @Produce json
+// @Param limit query int false "Number of history entries"
+// @Success 200 {object} map[string]interface{}
+// @Router /queue [get]
+func (w WindowController) GetQueueState(c *gin.Context) {
+	limit := 50
+	if l := c.Query("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil || limit <= 0 {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "limit must be a positive integer"})
+			return
+		}
+	}
+
+	db := models.GetDB()
+	var history []models.QueueEvent
+	if err := db.Order("id DESC").Limit(limit).Find(&history).Error; err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	paused := len(history) > 0 && history[0].Action == models.QueuePause
+	c.IndentedJSON(http.StatusOK, gin.H{"paused": paused, "history": history})
+}
+
+// PauseQueue godoc
+// @Summary Pause job handout
+// @Description Stop handing out jobs to every scanner until resumed. Running jobs continue. The pause is logged with the user and reason.
+// @Tags windows
+// @Accept json
+// @Produce json
+// @Param reason body models.QueueEventRequest false "Reason"
+// @Success 200 {object} models.QueueEvent
+// @Router /queue/pause [post]
+func (w WindowController) PauseQueue(c *gin.Context) {
+	setQueuePaused(c, models.QueuePause)
+}
+
+// ResumeQueue godoc
+// @Summary Resume job handout
+// @Description Resume handing out jobs after a pause. The resume is logged with the user and reason.
+// @Tags windows
+// @Accept json
+// @Produce json
+// @Param reason body models.QueueEventRequest false "Reason"
+// @Success 200 {object} models.QueueEvent
+// @Router /queue/resume [post]
+func (w WindowController) ResumeQueue(c *gin.Context) {
+	setQueuePaused(c, models.QueueResume)
+}
+
+// setQueuePaused records a pause or resume, refusing one that would not
+// change the state
+func setQueuePaused(c *gin.Context, action string) {
+	var req models.QueueEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	paused, err := models.QueuePaused()
+	if err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if paused && action == models.QueuePause {
+		c.IndentedJSON(http.StatusConflict, gin.H{"status": "error", "message": "job handout is already paused"})
+		return
+	}
+	if !paused && action == models.QueueResume {
+		c.IndentedJSON(http.StatusConflict, gin.H{"status": "error", "message": "job handout is not paused"})
+		return
+	}
+
+	event, err := models.RecordQueueEvent(action, c.GetString("user"), req.Reason)
+	if err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if action == models.QueueResume {
+		models.NotifyWork()
+	}
+	c.IndentedJSON(http.StatusOK, event)
+}
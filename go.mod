@@ -55,3 +55,7 @@ require (
 	google.golang.org/protobuf v1.32.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+// sessions v0.0.5 requires the retracted v2.0.3+incompatible tag, which is
+// older code than v1.14
+exclude github.com/mattn/go-sqlite3 v2.0.3+incompatible
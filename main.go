@@ -29,6 +29,9 @@ func main() {
 	// Initialize database
 	models.Init()
 
+	// Fail and requeue jobs whose scanner stopped sending heartbeats
+	models.StartJobReaper()
+
 	// Start server
 	server.Init()
 }
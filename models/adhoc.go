@@ -0,0 +1,89 @@
+package models
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// AdHocJobRequest queues an on-demand scan. Set team_id to scan a whole
+// team, host_ip to scan one host (its team is found from the IP when
+// team_id is empty), and ports to scan only those ports.
+type AdHocJobRequest struct {
+	JobType string `json:"job_type" binding:"required"`
+	TeamID  string `json:"team_id"`
+	HostIP  string `json:"host_ip"`
+	Ports   string `json:"ports"` // nmap -p syntax, replaces the job type's ports
+}
+
+// PortList is a parsed nmap -p style port list
+type PortList []portRange
+
+type portRange struct {
+	Protocol string // tcp, udp, sctp, or empty for any
+	Low      uint16
+	High     uint16
+}
+
+var portListProtocols = map[string]string{"T": "tcp", "U": "udp", "S": "sctp"}
+
+// ParsePortList parses a list such as "22,80,8000-8100" or "U:53,T:1-1024".
+// As with nmap, a protocol prefix applies to the entries after it.
+func ParsePortList(list string) (PortList, error) {
+	list = strings.ReplaceAll(list, " ", "")
+	if !portListPattern.MatchString(list) {
+		return nil, errors.New("invalid port list: " + list)
+	}
+
+	var ports PortList
+	protocol := ""
+	for _, entry := range strings.Split(list, ",") {
+		if prefix, rest, ok := strings.Cut(entry, ":"); ok {
+			protocol = portListProtocols[prefix]
+			entry = rest
+		}
+		lowStr, highStr, isRange := strings.Cut(entry, "-")
+		if !isRange {
+			highStr = lowStr
+		}
+		low, err := strconv.ParseUint(lowStr, 10, 16)
+		if err != nil {
+			return nil, errors.New("invalid port: " + lowStr)
+		}
+		high, err := strconv.ParseUint(highStr, 10, 16)
+		if err != nil {
+			return nil, errors.New("invalid port: " + highStr)
+		}
+		if low > high {
+			return nil, errors.New("invalid port range: " + entry)
+		}
+		ports = append(ports, portRange{Protocol: protocol, Low: uint16(low), High: uint16(high)})
+	}
+	return ports, nil
+}
+
+// Contains reports whether a port is in the list
+func (l PortList) Contains(number uint16, protocol string) bool {
+	for _, r := range l {
+		if (r.Protocol == "" || r.Protocol == protocol) && number >= r.Low && number <= r.High {
+			return true
+		}
+	}
+	return false
+}
+
+// PortCoverage is the set of ports a scan probed, so that only those can be
+// closed when the scan did not find them open. The zero value is an unknown
+// set, which closes nothing.
+type PortCoverage struct {
+	All   bool     // every port, as with the scanner's default scan
+	Ports PortList // otherwise only these
+}
+
+// AllPorts is the coverage of a scan that is authoritative for every port
+var AllPorts = PortCoverage{All: true}
+
+// Contains reports whether the scan probed a port
+func (c PortCoverage) Contains(number uint16, protocol string) bool {
+	return c.All || c.Ports.Contains(number, protocol)
+}
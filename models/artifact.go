@@ -0,0 +1,118 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Artifact kinds
+const (
+	ArtifactScan       = "scan"       // the body uploaded as the job's results
+	ArtifactAttachment = "attachment" // an extra file sent by the scanner
+)
+
+// scanArtifactNames gives the download name of a results upload by format
+var scanArtifactNames = map[string]string{
+	ScanFormatJSON:        "scan.json",
+	ScanFormatNmapXML:     "nmap.xml",
+	ScanFormatMasscanJSON: "masscan.json",
+	ScanFormatMasscanList: "masscan.txt",
+	ScanFormatNaabu:       "naabu.jsonl",
+	ScanFormatRustscan:    "rustscan.txt",
+}
+
+// Artifact is a raw file kept with a job. The content lives in the artifact
+// store under its SHA-256, so identical uploads are stored once.
+type Artifact struct {
+	gorm.Model  `json:"-"`
+	AID         string    `json:"aid" gorm:"uniqueIndex;column:a_id"`
+	JID         string    `json:"jid" gorm:"column:j_id;index"`
+	Kind        string    `json:"kind"`
+	Name        string    `json:"name"`
+	Format      string    `json:"format,omitempty"` // scan format, if the file can be ingested
+	ContentType string    `json:"content_type"`
+	SHA256      string    `json:"sha256" gorm:"index"`
+	Size        int64     `json:"size"`
+	UploadedBy  string    `json:"uploaded_by"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+}
+
+// ArtifactDir is where artifact contents are stored (env ARTIFACT_DIR,
+// default ./artifacts)
+func ArtifactDir() string {
+	if dir := os.Getenv("ARTIFACT_DIR"); dir != "" {
+		return dir
+	}
+	return "artifacts"
+}
+
+// ScanArtifactName returns the download name for a results upload
+func ScanArtifactName(format string) string {
+	if name, ok := scanArtifactNames[format]; ok {
+		return name
+	}
+	return format + ".txt"
+}
+
+func MakeArtifact(jid string, kind string, name string, data []byte) Artifact {
+	var a Artifact
+	sum := sha256.Sum256(data)
+	a.AID = uuid.New().String()
+	a.JID = jid
+	a.Kind = kind
+	a.Name = name
+	a.SHA256 = hex.EncodeToString(sum[:])
+	a.Size = int64(len(data))
+	a.UploadedAt = time.Now()
+	return a
+}
+
+// Path returns where the artifact's content is stored
+func (a Artifact) Path() string {
+	return filepath.Join(ArtifactDir(), a.SHA256[:2], a.SHA256)
+}
+
+// Read returns the artifact's content
+func (a Artifact) Read() ([]byte, error) {
+	return os.ReadFile(a.Path())
+}
+
+// writeContent stores data under the artifact's hash unless it is already
+// there. A temporary file is renamed into place so a crash never leaves a
+// truncated file under a valid hash.
+func (a Artifact) writeContent(data []byte) error {
+	path := a.Path()
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), a.SHA256+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// StoreArtifact writes the artifact's content to the store and records it
+func StoreArtifact(a *Artifact, data []byte) error {
+	if err := a.writeContent(data); err != nil {
+		return err
+	}
+	return GetDB().Create(a).Error
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronExpr is a parsed five-field cron expression:
+// minute hour day-of-month month day-of-week
+type CronExpr struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCron parses a standard cron expression. Fields accept *, numbers,
+// ranges (1-5), lists (1,15,30) and steps (*/10, 0-30/5). Day of week runs
+// from 0 (Sunday) to 7 (also Sunday). The @hourly style macros are accepted.
+func ParseCron(expr string) (CronExpr, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := cronMacros[expr]; ok {
+		expr = macro
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return CronExpr{}, errors.New("cron expression must have 5 fields: minute hour day month weekday")
+	}
+
+	var c CronExpr
+	var err error
+	if c.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return CronExpr{}, errors.New("invalid cron minute: " + err.Error())
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return CronExpr{}, errors.New("invalid cron hour: " + err.Error())
+	}
+	if c.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return CronExpr{}, errors.New("invalid cron day of month: " + err.Error())
+	}
+	if c.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return CronExpr{}, errors.New("invalid cron month: " + err.Error())
+	}
+	if c.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return CronExpr{}, errors.New("invalid cron day of week: " + err.Error())
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domAny = strings.HasPrefix(fields[2], "*")
+	c.dowAny = strings.HasPrefix(fields[4], "*")
+	return c, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n < 1 {
+				return 0, errors.New("bad step in " + item)
+			}
+			step = n
+		}
+
+		lo, hi := min, max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			a, b, _ := strings.Cut(rng, "-")
+			var errA, errB error
+			lo, errA = strconv.Atoi(a)
+			hi, errB = strconv.Atoi(b)
+			if errA != nil || errB != nil {
+				return 0, errors.New("bad range " + rng)
+			}
+		default:
+			n, err := strconv.Atoi(rng)
+			if err != nil {
+				return 0, errors.New("bad value " + rng)
+			}
+			lo = n
+			if !hasStep {
+				hi = n
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, errors.New(item + " is out of range")
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (c CronExpr) dayMatches(t time.Time) bool {
+	domMatch := c.dom&(1<<uint(t.Day())) != 0
+	dowMatch := c.dow&(1<<uint(t.Weekday())) != 0
+	// Like cron, a restricted day of month and day of week match either one
+	if !c.domAny && !c.dowAny {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+// Next returns the first time after t that matches the expression, or the
+// zero time if none does within five years
+func (c CronExpr) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr string
+	}{
+		{"* * * * *", ""},
+		{"*/15 9-17 * * 1-5", ""},
+		{"0,30 */2 1,15 1-12/3 0-6", ""},
+		{"0 0 * * 7", ""},
+		{" @hourly ", ""},
+		{"@weekly", ""},
+		{"", "must have 5 fields"},
+		{"* * * *", "must have 5 fields"},
+		{"* * * * * *", "must have 5 fields"},
+		{"@fortnightly", "must have 5 fields"},
+		{"60 * * * *", "invalid cron minute"},
+		{"*/0 * * * *", "invalid cron minute"},
+		{"a * * * *", "invalid cron minute"},
+		{"* 24 * * *", "invalid cron hour"},
+		{"* 5-1 * * *", "invalid cron hour"},
+		{"* 1-b * * *", "invalid cron hour"},
+		{"* * 0 * *", "invalid cron day of month"},
+		{"* * 32 * *", "invalid cron day of month"},
+		{"* * * 13 *", "invalid cron month"},
+		{"* * * JAN *", "invalid cron month"},
+		{"* * * * 8", "invalid cron day of week"},
+	}
+	for _, tt := range tests {
+		_, err := ParseCron(tt.expr)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("ParseCron(%q): %v", tt.expr, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("ParseCron(%q) err = %v, want %q", tt.expr, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// A Wednesday
+	base := time.Date(2024, 5, 29, 16, 26, 40, 0, time.UTC)
+	at := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2024, month, day, hour, min, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"every minute", "* * * * *", base, at(5, 29, 16, 27)},
+		{"strictly after", "* * * * *", at(5, 29, 16, 27), at(5, 29, 16, 28)},
+		{"minute step", "*/15 * * * *", base, at(5, 29, 16, 30)},
+		{"hourly macro", "@hourly", base, at(5, 29, 17, 0)},
+		{"hour rolls to next day", "0 9 * * *", base, at(5, 30, 9, 0)},
+		{"weekdays", "0 9 * * 1-5", at(5, 31, 10, 0), at(6, 3, 9, 0)},
+		{"sunday as 7", "0 0 * * 7", base, at(6, 2, 0, 0)},
+		{"sunday as 0", "0 0 * * 0", base, at(6, 2, 0, 0)},
+		{"first of month", "0 0 1 * *", base, at(6, 1, 0, 0)},
+		{"31st skips short months", "0 0 31 * *", at(5, 31, 0, 0), at(7, 31, 0, 0)},
+		{"next year", "0 0 1 1 *", base, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"leap day", "0 0 29 2 *", base, time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"never", "0 0 30 2 *", base, time.Time{}},
+
+		// A restricted day of month and day of week match either one
+		{"dom or dow, dom first", "0 0 1 * 1", base, at(6, 1, 0, 0)},
+		{"dom or dow, dow first", "0 0 1 * 1", at(6, 1, 0, 0), at(6, 3, 0, 0)},
+		{"dom or dow, both in list", "0 12 15,20 * 5", at(6, 8, 0, 0), at(6, 14, 12, 0)},
+		// When either is *, both must match
+		{"dom only", "0 0 15 * *", base, at(6, 15, 0, 0)},
+		{"dow only", "0 0 * * 1", base, at(6, 3, 0, 0)},
+		// A field starting with * counts as unrestricted, even with a step
+		{"starred dow step", "0 0 1 * */2", base, at(6, 1, 0, 0)},
+		{"starred dom step", "0 0 */10 * 2", base, at(6, 11, 0, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := c.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.from, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCronDayMatches(t *testing.T) {
+	// 2024-06-01 is a Saturday, 2024-06-03 a Monday
+	sat := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	mon := time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)
+	tue := time.Date(2024, 6, 4, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		expr          string
+		sat, mon, tue bool
+	}{
+		{"0 0 * * *", true, true, true},
+		{"0 0 1 * *", true, false, false},
+		{"0 0 * * 1", false, true, false},
+		{"0 0 1 * 1", true, true, false},
+		{"0 0 1-3 * 2", true, true, true},
+		{"0 0 */2 * 1", false, true, false},
+		{"0 0 4 * */3", false, false, false},
+	}
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.dayMatches(sat); got != tt.sat {
+			t.Errorf("%q on Saturday 1st = %v", tt.expr, got)
+		}
+		if got := c.dayMatches(mon); got != tt.mon {
+			t.Errorf("%q on Monday 3rd = %v", tt.expr, got)
+		}
+		if got := c.dayMatches(tue); got != tt.tue {
+			t.Errorf("%q on Tuesday 4th = %v", tt.expr, got)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecordScanCycle writes the ScanHistory entry for a finished job. A job
+// that is one chunk of a larger scan only records once no other chunk of
+// its cycle is queued or running; the entry then totals every chunk. Ad hoc
+// jobs are not part of the regular scan history.
+func RecordScanCycle(tx *gorm.DB, job Job) error {
+	if job.AdHoc {
+		return nil
+	}
+	if job.CycleID == "" {
+		if job.Status != "complete" {
+			return nil
+		}
+		history := ScanHistory{
+			TeamID:       job.TID,
+			ScanTime:     time.Now(),
+			HostCount:    job.HostsFound,
+			PortCount:    job.PortsFound,
+			NewPorts:     job.NewPorts,
+			MissingPorts: job.MissingPorts,
+		}
+		return tx.Create(&history).Error
+	}
+
+	var pending int64
+	result := tx.Model(&Job{}).Where("cycle_id = ? AND status IN ?", job.CycleID, []string{"queued", "running"}).Count(&pending)
+	if result.Error != nil || pending > 0 {
+		return result.Error
+	}
+
+	var chunks []Job
+	if err := tx.Where("cycle_id = ? AND status IN ?", job.CycleID, []string{"complete", "failed"}).Find(&chunks).Error; err != nil {
+		return err
+	}
+
+	// Retried chunks appear more than once; a chunk failed only if no attempt completed
+	history := ScanHistory{TeamID: job.TID, ScanTime: time.Now(), CycleID: job.CycleID, Chunks: job.ChunkCount}
+	completed := make(map[int]bool)
+	for _, chunk := range chunks {
+		if chunk.Status == "complete" {
+			completed[chunk.ChunkIndex] = true
+			history.HostCount += chunk.HostsFound
+			history.PortCount += chunk.PortsFound
+			history.NewPorts += chunk.NewPorts
+			history.MissingPorts += chunk.MissingPorts
+		}
+	}
+	if len(completed) == 0 {
+		return nil
+	}
+	history.FailedChunks = job.ChunkCount - len(completed)
+	return tx.Create(&history).Error
+}
@@ -44,11 +44,21 @@ func Init() {
 	db.AutoMigrate(&Host{})
 	db.AutoMigrate(&Port{})
 	db.AutoMigrate(&ScriptResult{})
+	db.AutoMigrate(&HostScript{})
+	db.AutoMigrate(&Finding{})
 	db.AutoMigrate(&Team{})
 	db.AutoMigrate(&Job{})
 	db.AutoMigrate(&JobStatus{})
 	db.AutoMigrate(&PortBaseline{})
 	db.AutoMigrate(&ScanHistory{})
+	db.AutoMigrate(&PortEvent{})
+	db.AutoMigrate(&Schedule{})
+	db.AutoMigrate(&Scanner{})
+	db.AutoMigrate(&Exclusion{})
+	db.AutoMigrate(&ScanWindow{})
+	db.AutoMigrate(&QueueEvent{})
+	db.AutoMigrate(&Artifact{})
+	db.AutoMigrate(&JobLog{})
 
 	// Create indexes for better query performance
 	db.Exec("CREATE INDEX IF NOT EXISTS idx_hosts_team_id ON hosts(team_id)")
@@ -0,0 +1,241 @@
+package models
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Event types published on the event stream
+const (
+	EventHostDiscovered = "host.discovered"
+	EventHostOnline     = "host.online" // a known host was seen again
+	EventHostOffline    = "host.offline"
+	EventPortOpened     = "port.opened"
+	EventPortClosed     = "port.closed"
+	EventPortChanged    = "port.changed" // service or version changed
+	EventFindingCreated = "finding.created"
+	EventJobState       = "job.state"
+	EventJobProgress    = "job.progress"
+)
+
+// eventBacklog is how many recent events are kept for clients that
+// reconnect with Last-Event-ID
+const eventBacklog = 1000
+
+// subscriberBuffer is how many events a slow client may fall behind by
+// before it is disconnected (it then reconnects and catches up)
+const subscriberBuffer = 256
+
+// Event is a change published to event stream subscribers
+type Event struct {
+	ID     uint64      `json:"id"`
+	Type   string      `json:"type"`
+	TeamID string      `json:"team_id,omitempty"`
+	Time   time.Time   `json:"time"`
+	Data   interface{} `json:"data"`
+}
+
+// HostEvent is the data of host events
+type HostEvent struct {
+	TeamID   string `json:"team_id"`
+	IP       string `json:"ip"`
+	Hostname string `json:"hostname"`
+	OS       string `json:"os"`
+	Status   string `json:"status"`
+	JID      string `json:"jid,omitempty"`
+}
+
+// PortChangeEvent is the data of port events: the port event log entry
+// plus the port's state after the change
+type PortChangeEvent struct {
+	PortEvent
+	Service string `json:"service"`
+	Version string `json:"version"`
+	State   string `json:"state"`
+}
+
+// FindingEvent is the data of finding.created events
+type FindingEvent struct {
+	TeamID     string `json:"team_id"`
+	HostIP     string `json:"host_ip"`
+	Hostname   string `json:"hostname"`
+	Port       uint16 `json:"port"`
+	Protocol   string `json:"protocol"`
+	Service    string `json:"service"`
+	Source     string `json:"source"`
+	TemplateID string `json:"template_id"`
+	Name       string `json:"name"`
+	Severity   string `json:"severity"`
+	MatchedAt  string `json:"matched_at,omitempty"`
+	Extracted  string `json:"extracted,omitempty"`
+	Output     string `json:"output,omitempty"` // report findings and rated script results
+}
+
+// JobEvent is the data of job events
+type JobEvent struct {
+	JID        string     `json:"jid"`
+	Type       string     `json:"type"`
+	TeamID     string     `json:"tid"`
+	TeamName   string     `json:"team_name"`
+	Status     string     `json:"status"`
+	Scanner    string     `json:"scanner,omitempty"`
+	AdHoc      bool       `json:"ad_hoc,omitempty"`
+	ErrorMsg   string     `json:"error_msg,omitempty"`
+	Percent    float64    `json:"percent"`
+	HostsDone  int        `json:"hosts_done"`
+	HostsTotal int        `json:"hosts_total"`
+	ETA        *time.Time `json:"eta,omitempty"`
+}
+
+// MakeJobEvent is the event stream data for a job
+func MakeJobEvent(job Job) JobEvent {
+	e := JobEvent{
+		JID:        job.JID,
+		Type:       job.Type,
+		TeamID:     job.TID,
+		TeamName:   job.TeamName,
+		Status:     job.Status,
+		Scanner:    job.Scanner,
+		AdHoc:      job.AdHoc,
+		ErrorMsg:   job.ErrorMsg,
+		Percent:    job.Percent,
+		HostsDone:  job.HostsDone,
+		HostsTotal: job.HostsTotal,
+	}
+	if !job.ETA.IsZero() {
+		e.ETA = &job.ETA
+	}
+	return e
+}
+
+var eventHub = struct {
+	sync.Mutex
+	nextID uint64
+	recent []Event
+	subs   map[chan Event]struct{}
+}{nextID: 1, subs: make(map[chan Event]struct{})}
+
+// PublishEvent sends an event to every subscriber
+func PublishEvent(eventType string, teamID string, data interface{}) {
+	eventHub.Lock()
+	defer eventHub.Unlock()
+
+	e := Event{ID: eventHub.nextID, Type: eventType, TeamID: teamID, Time: time.Now(), Data: data}
+	eventHub.nextID++
+	eventHub.recent = append(eventHub.recent, e)
+	if len(eventHub.recent) > eventBacklog {
+		eventHub.recent = eventHub.recent[len(eventHub.recent)-eventBacklog:]
+	}
+
+	for ch := range eventHub.subs {
+		select {
+		case ch <- e:
+		default:
+			// Too far behind; closing makes the client reconnect and replay
+			delete(eventHub.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// PublishJobState announces a job's new status
+func PublishJobState(job Job) {
+	PublishEvent(EventJobState, job.TID, MakeJobEvent(job))
+}
+
+// Subscription receives published events until it is closed
+type Subscription struct {
+	// Events is closed if the subscriber falls too far behind
+	Events <-chan Event
+	// Missed holds the events after the requested ID, to replay first
+	Missed []Event
+	// Gap is set when some events after the requested ID are no longer
+	// kept (or the server restarted), so the client must reload instead
+	Gap bool
+
+	ch chan Event
+}
+
+// SubscribeEvents registers a subscriber. Events after lastID that are still
+// in the backlog are returned for replay; pass 0 for none.
+func SubscribeEvents(lastID uint64) *Subscription {
+	eventHub.Lock()
+	defer eventHub.Unlock()
+
+	ch := make(chan Event, subscriberBuffer)
+	sub := &Subscription{Events: ch, ch: ch}
+	if lastID > 0 {
+		switch {
+		case lastID >= eventHub.nextID:
+			sub.Gap = true
+		case len(eventHub.recent) > 0 && eventHub.recent[0].ID > lastID+1:
+			sub.Gap = true
+		default:
+			for _, e := range eventHub.recent {
+				if e.ID > lastID {
+					sub.Missed = append(sub.Missed, e)
+				}
+			}
+		}
+	}
+	eventHub.subs[ch] = struct{}{}
+	return sub
+}
+
+// Close unregisters the subscriber
+func (s *Subscription) Close() {
+	eventHub.Lock()
+	defer eventHub.Unlock()
+	if _, ok := eventHub.subs[s.ch]; ok {
+		delete(eventHub.subs, s.ch)
+		close(s.ch)
+	}
+}
+
+type eventBufferKey struct{}
+
+// EventBuffer holds events raised inside a transaction until it commits
+type EventBuffer struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+// BufferEvents returns a session of db whose events are held in the returned
+// buffer. Begin the transaction on it and Publish the buffer after commit, so
+// subscribers never see changes that were rolled back.
+func BufferEvents(db *gorm.DB) (*gorm.DB, *EventBuffer) {
+	buf := &EventBuffer{}
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return db.WithContext(context.WithValue(ctx, eventBufferKey{}, buf)), buf
+}
+
+// RaiseEvent adds an event to tx's buffer, or publishes it at once if tx
+// has none
+func RaiseEvent(tx *gorm.DB, eventType string, teamID string, data interface{}) {
+	if tx.Statement.Context != nil {
+		if buf, ok := tx.Statement.Context.Value(eventBufferKey{}).(*EventBuffer); ok {
+			buf.mu.Lock()
+			buf.events = append(buf.events, Event{Type: eventType, TeamID: teamID, Data: data})
+			buf.mu.Unlock()
+			return
+		}
+	}
+	PublishEvent(eventType, teamID, data)
+}
+
+// Publish sends the buffered events
+func (b *EventBuffer) Publish() {
+	b.mu.Lock()
+	events := b.events
+	b.events = nil
+	b.mu.Unlock()
+	for _, e := range events {
+		PublishEvent(e.Type, e.TeamID, e.Data)
+	}
+}
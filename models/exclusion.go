@@ -0,0 +1,78 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Exclusion is an address range that must never be scanned, such as a
+// scoring engine or white team infrastructure. An exclusion without a team
+// applies to every team.
+type Exclusion struct {
+	gorm.Model `json:"-"`
+	EID        string `json:"eid" gorm:"uniqueIndex;column:e_id"`
+	TeamID     string `json:"team_id" gorm:"index"` // empty for global exclusions
+	Target     string `json:"target"`               // IP, CIDR or range, as for team IP ranges
+	Reason     string `json:"reason"`
+}
+
+// ExclusionRequest for creating exclusions via API
+type ExclusionRequest struct {
+	TeamID string `json:"team_id"`
+	Target string `json:"target" binding:"required"`
+	Reason string `json:"reason"`
+}
+
+func MakeExclusion(teamID string, target string) Exclusion {
+	var e Exclusion
+	e.EID = uuid.New().String()
+	e.TeamID = teamID
+	e.Target = strings.ReplaceAll(target, " ", "")
+	return e
+}
+
+// ValidateExclusionTarget checks a target the same way as team IP ranges,
+// and that it can be matched against scanned addresses
+func ValidateExclusionTarget(target string) error {
+	if err := ValidateIPRange(target); err != nil {
+		return err
+	}
+	_, err := ParseIPSet(target)
+	return err
+}
+
+// ExclusionList holds the exclusions that apply to one team
+type ExclusionList struct {
+	Targets []string
+	sets    []IPSet
+}
+
+// Contains reports whether an address is excluded
+func (l ExclusionList) Contains(ip string) bool {
+	for _, set := range l.sets {
+		if set.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// TeamExclusions returns the global exclusions plus those of a team
+func TeamExclusions(tx *gorm.DB, teamID string) (ExclusionList, error) {
+	var exclusions []Exclusion
+	list := ExclusionList{Targets: []string{}}
+	if err := tx.Where("team_id = ? OR team_id = ?", "", teamID).Order("id ASC").Find(&exclusions).Error; err != nil {
+		return list, err
+	}
+	for _, e := range exclusions {
+		set, err := ParseIPSet(e.Target)
+		if err != nil {
+			continue
+		}
+		list.Targets = append(list.Targets, e.Target)
+		list.sets = append(list.sets, set)
+	}
+	return list, nil
+}
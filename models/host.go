@@ -1,8 +1,11 @@
 package models
 
 import (
+	"errors"
+	"net"
 	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -12,6 +15,7 @@ type Host struct {
 	Hostname   string `json:"hostname"`
 	OS         string `json:"os"`
 	Ports      []Port `json:"ports" gorm:"foreignKey:HostID;constraint:OnDelete:CASCADE"`
+	Scripts    []HostScript `json:"scripts,omitempty" gorm:"foreignKey:HostID;constraint:OnDelete:CASCADE"`
 	TeamID     string `json:"team_id" gorm:"index"`
 	LastSeen   time.Time `json:"last_seen"`
 	Status     string `json:"status"` // online, offline, unknown
@@ -20,12 +24,56 @@ type Host struct {
 // PortBaseline defines expected ports for monitoring
 type PortBaseline struct {
 	gorm.Model `json:"-"`
+	BID        string `json:"bid" gorm:"uniqueIndex;column:b_id"`
 	TeamID     string `json:"team_id" gorm:"index"`
 	HostIP     string `json:"host_ip"` // Can be "*" for all hosts in team
 	Port       uint16 `json:"port"`
 	Protocol   string `json:"protocol"`
 	Service    string `json:"service"`
-	Expected   bool   `json:"expected"` // true = expected, false = should alert if found
+	Expected   bool   `json:"expected"` // true = expected, false = only marked new if found, like an unlisted port
+}
+
+// BaselineRequest for creating/updating port baselines via API
+type BaselineRequest struct {
+	HostIP   string `json:"host_ip" binding:"required"`
+	Port     uint16 `json:"port" binding:"required"`
+	Protocol string `json:"protocol"`
+	Service  string `json:"service"`
+	Expected *bool  `json:"expected"` // defaults to true
+}
+
+// Baseline snapshot modes
+const (
+	BaselineModeMerge   = "merge"   // keep existing entries, add what is missing
+	BaselineModeReplace = "replace" // drop existing host entries first
+)
+
+// BaselineSnapshotRequest for copying observed ports into a baseline
+type BaselineSnapshotRequest struct {
+	Mode   string `json:"mode"`    // merge (default) or replace
+	HostIP string `json:"host_ip"` // limit the snapshot to one host
+}
+
+func MakeBaseline(teamID string, hostIP string, port uint16, protocol string) PortBaseline {
+	var pb PortBaseline
+	pb.BID = uuid.New().String()
+	pb.TeamID = teamID
+	pb.HostIP = hostIP
+	pb.Port = port
+	pb.Protocol = protocol
+	pb.Expected = true
+	return pb
+}
+
+// ValidateBaseline checks a baseline's host and protocol
+func ValidateBaseline(hostIP string, protocol string) error {
+	if hostIP != "*" && net.ParseIP(hostIP) == nil {
+		return errors.New("host_ip must be an IP address or \"*\"")
+	}
+	if protocol != "tcp" && protocol != "udp" {
+		return errors.New("protocol must be tcp or udp")
+	}
+	return nil
 }
 
 // ScanHistory tracks scan results over time
@@ -37,4 +85,7 @@ type ScanHistory struct {
 	PortCount   int       `json:"port_count"`
 	NewPorts    int       `json:"new_ports"`    // Ports not in baseline
 	MissingPorts int      `json:"missing_ports"` // Expected ports not found
+	CycleID     string    `json:"cycle_id,omitempty"` // set when the scan was split into chunks
+	Chunks      int       `json:"chunks,omitempty"`
+	FailedChunks int      `json:"failed_chunks,omitempty"`
 }
@@ -0,0 +1,304 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// ipSpan is an inclusive range of addresses
+type ipSpan struct {
+	Start netip.Addr
+	End   netip.Addr
+}
+
+// IPSet is a parsed IP range expression, as accepted by ValidateIPRange:
+// CIDR blocks, last-octet ranges (192.168.1.1-254), single IPs and
+// comma-separated lists of those.
+type IPSet struct {
+	spans []ipSpan
+}
+
+// ParseIPSet parses an IP range expression into an IPSet
+func ParseIPSet(iprange string) (IPSet, error) {
+	var set IPSet
+	for _, part := range strings.Split(iprange, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		span, err := parseIPSpan(part)
+		if err != nil {
+			return IPSet{}, err
+		}
+		set.spans = append(set.spans, span)
+	}
+	if len(set.spans) == 0 {
+		return IPSet{}, errors.New("IP range cannot be empty")
+	}
+	return set, nil
+}
+
+func parseIPSpan(part string) (ipSpan, error) {
+	// CIDR notation (e.g., 192.168.1.0/24)
+	if strings.Contains(part, "/") {
+		prefix, err := netip.ParsePrefix(part)
+		if err != nil {
+			return ipSpan{}, errors.New("invalid IP address or range: " + part)
+		}
+		prefix = prefix.Masked()
+		return ipSpan{Start: prefix.Addr(), End: lastAddr(prefix)}, nil
+	}
+
+	// Range notation (e.g., 192.168.1.1-254)
+	if startStr, endStr, found := strings.Cut(part, "-"); found {
+		start, err := netip.ParseAddr(startStr)
+		if err != nil || !start.Is4() {
+			return ipSpan{}, errors.New("invalid IP address or range: " + part)
+		}
+		last, err := strconv.Atoi(endStr)
+		if err != nil || last < 0 || last > 255 {
+			return ipSpan{}, errors.New("invalid IP address or range: " + part)
+		}
+		octets := start.As4()
+		if last < int(octets[3]) {
+			return ipSpan{}, errors.New("invalid IP address or range: " + part)
+		}
+		octets[3] = byte(last)
+		return ipSpan{Start: start, End: netip.AddrFrom4(octets)}, nil
+	}
+
+	// Single IP
+	addr, err := netip.ParseAddr(part)
+	if err != nil {
+		return ipSpan{}, errors.New("invalid IP address or range: " + part)
+	}
+	return ipSpan{Start: addr, End: addr}, nil
+}
+
+// lastAddr returns the highest address in a prefix
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	bytes := prefix.Addr().AsSlice()
+	bits := prefix.Bits()
+	for i := range bytes {
+		hostBits := len(bytes)*8 - bits - (len(bytes)-1-i)*8
+		if hostBits >= 8 {
+			bytes[i] = 0xff
+		} else if hostBits > 0 {
+			bytes[i] |= byte(1<<hostBits) - 1
+		}
+	}
+	addr, _ := netip.AddrFromSlice(bytes)
+	return addr
+}
+
+// Contains reports whether ip falls inside the set
+func (s IPSet) Contains(ip string) bool {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, span := range s.spans {
+		if span.Start.BitLen() != addr.BitLen() {
+			continue
+		}
+		if span.Start.Compare(addr) <= 0 && addr.Compare(span.End) <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Overlaps reports whether any address is in both sets
+func (s IPSet) Overlaps(other IPSet) bool {
+	for _, a := range s.spans {
+		for _, b := range other.spans {
+			if a.Start.BitLen() != b.Start.BitLen() {
+				continue
+			}
+			if a.Start.Compare(b.End) <= 0 && b.Start.Compare(a.End) <= 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Offset returns the position of ip within the set, counting addresses
+// across spans in the order they were written. Teams with identical
+// infrastructure put the same machine at the same offset in their range.
+func (s IPSet) Offset(ip string) (uint64, bool) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return 0, false
+	}
+	addr = addr.Unmap()
+	var base uint64
+	for _, span := range s.spans {
+		size, ok := addrDiff(span.End, span.Start)
+		if !ok {
+			return 0, false
+		}
+		if span.Start.BitLen() == addr.BitLen() && span.Start.Compare(addr) <= 0 && addr.Compare(span.End) <= 0 {
+			off, _ := addrDiff(addr, span.Start)
+			return base + off, true
+		}
+		base += size + 1
+	}
+	return 0, false
+}
+
+// AddrAt returns the address at offset within the set, the inverse of Offset
+func (s IPSet) AddrAt(offset uint64) (string, bool) {
+	for _, span := range s.spans {
+		size, ok := addrDiff(span.End, span.Start)
+		if !ok {
+			return "", false
+		}
+		if offset <= size {
+			return addrAdd(span.Start, offset).String(), true
+		}
+		offset -= size + 1
+	}
+	return "", false
+}
+
+// addrDiff returns a-b, failing if the difference does not fit in 64 bits
+func addrDiff(a, b netip.Addr) (uint64, bool) {
+	ab, bb := a.As16(), b.As16()
+	var diff [16]byte
+	borrow := 0
+	for i := 15; i >= 0; i-- {
+		d := int(ab[i]) - int(bb[i]) - borrow
+		borrow = 0
+		if d < 0 {
+			d += 256
+			borrow = 1
+		}
+		diff[i] = byte(d)
+	}
+	var n uint64
+	for i := 0; i < 16; i++ {
+		if i < 8 && diff[i] != 0 {
+			return 0, false
+		}
+		if i >= 8 {
+			n = n<<8 | uint64(diff[i])
+		}
+	}
+	return n, borrow == 0
+}
+
+// addrAdd returns a+n
+func addrAdd(a netip.Addr, n uint64) netip.Addr {
+	bytes := a.AsSlice()
+	carry := n
+	for i := len(bytes) - 1; i >= 0 && carry > 0; i-- {
+		sum := uint64(bytes[i]) + carry&0xff
+		bytes[i] = byte(sum)
+		carry = carry>>8 + sum>>8
+	}
+	addr, _ := netip.AddrFromSlice(bytes)
+	return addr
+}
+
+// ParseChunkSize parses a sub-job size: either a host count ("64") or an
+// IPv4 prefix length ("/26" = 64 hosts). Empty means no splitting.
+func ParseChunkSize(size string) (uint64, error) {
+	size = strings.TrimSpace(size)
+	if size == "" {
+		return 0, nil
+	}
+	if bits, found := strings.CutPrefix(size, "/"); found {
+		n, err := strconv.Atoi(bits)
+		if err != nil || n < 1 || n > 32 {
+			return 0, errors.New("chunk prefix must be between /1 and /32")
+		}
+		return 1 << (32 - n), nil
+	}
+	n, err := strconv.ParseUint(size, 10, 64)
+	if err != nil || n == 0 {
+		return 0, errors.New("chunk size must be a host count or a prefix like /26")
+	}
+	return n, nil
+}
+
+// MaxChunks caps how many sub-jobs a team range may be split into
+const MaxChunks = 4096
+
+// Chunks splits the set into IP range expressions of at most size addresses
+// each, in order. A chunk never spans two comma-separated parts of the
+// original range. Each chunk is written as a comma-separated CIDR list.
+// IPv6 spans larger than one chunk, and splits into more than MaxChunks
+// chunks, are refused.
+func (s IPSet) Chunks(size uint64) ([]string, error) {
+	var count uint64
+	for _, span := range s.spans {
+		last, ok := addrDiff(span.End, span.Start)
+		if span.Start.Is6() && (!ok || last >= size) {
+			return nil, errors.New("IPv6 ranges cannot be split into chunks")
+		}
+		count += last/size + 1
+		if count > MaxChunks {
+			return nil, fmt.Errorf("range would be split into more than %d chunks, use a larger chunk size", MaxChunks)
+		}
+	}
+
+	chunks := make([]string, 0, count)
+	for _, span := range s.spans {
+		start := span.Start
+		for {
+			end := span.End
+			if remaining, ok := addrDiff(span.End, start); !ok || remaining >= size {
+				end = addrAdd(start, size-1)
+			}
+			chunks = append(chunks, cidrCover(start, end))
+			if end.Compare(span.End) >= 0 {
+				break
+			}
+			start = end.Next()
+		}
+	}
+	return chunks, nil
+}
+
+// CheckChunks reports whether a team range can be split by a job type's
+// chunk size
+func CheckChunks(iprange string, chunkSize string) error {
+	size, err := ParseChunkSize(chunkSize)
+	if err != nil || size == 0 {
+		return err
+	}
+	set, err := ParseIPSet(iprange)
+	if err != nil {
+		return err
+	}
+	_, err = set.Chunks(size)
+	return err
+}
+
+// cidrCover writes the inclusive range start-end as the fewest CIDR blocks
+func cidrCover(start, end netip.Addr) string {
+	var parts []string
+	for start.IsValid() && start.Compare(end) <= 0 {
+		bits := start.BitLen()
+		for bits > 0 {
+			prefix := netip.PrefixFrom(start, bits-1).Masked()
+			if prefix.Addr() != start || lastAddr(prefix).Compare(end) > 0 {
+				break
+			}
+			bits--
+		}
+		prefix := netip.PrefixFrom(start, bits)
+		if bits == start.BitLen() {
+			parts = append(parts, start.String())
+		} else {
+			parts = append(parts, prefix.String())
+		}
+		start = lastAddr(prefix).Next()
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,78 @@
+package models
+
+import "testing"
+
+func TestIPSetOverlaps(t *testing.T) {
+	mustParse := func(iprange string) IPSet {
+		t.Helper()
+		set, err := ParseIPSet(iprange)
+		if err != nil {
+			t.Fatalf("ParseIPSet(%q): %v", iprange, err)
+		}
+		return set
+	}
+
+	full := mustParse("10.0.1.0/24")
+	chunk := mustParse("10.0.1.64/26")
+	if !full.Overlaps(chunk) || !chunk.Overlaps(full) {
+		t.Error("a chunk should overlap the range it was split from, both ways")
+	}
+
+	// Spans that only touch at one address still share it
+	if !mustParse("10.0.1.1-10").Overlaps(mustParse("10.0.1.10-20")) {
+		t.Error("ranges sharing their last address should overlap")
+	}
+	if mustParse("10.0.1.1-9").Overlaps(mustParse("10.0.1.10-20")) {
+		t.Error("adjacent ranges should not overlap")
+	}
+
+	// Only one part of a list has to meet the other set
+	if !mustParse("10.0.2.0/24, 10.0.1.5").Overlaps(full) {
+		t.Error("a list should overlap when one of its parts does")
+	}
+	if mustParse("::ffff:0:0/96").Overlaps(full) {
+		t.Error("IPv6 and IPv4 spans should never overlap")
+	}
+}
+
+// ValidateIPRange guards what is stored on a team, and ParseIPSet is what
+// splits and scopes it later, so the two must agree on every input
+func TestValidateIPRangeMatchesParseIPSet(t *testing.T) {
+	valid := []string{
+		"10.0.0.0/24",
+		" 10.0.0.0/24 ",
+		"10.0.0.1",
+		"10.0.0.1-254",
+		"10.0.0.5-5",
+		"10.0.0.1, 10.0.1.0/28, 10.0.2.10-20",
+		"fd00::/64",
+	}
+	invalid := []string{
+		"",
+		" , ",
+		"999.999.999.999-1",
+		"10.0.0.1-300",
+		"10.0.0.5-2",
+		"10.0.0.1-",
+		"fd00::1-5",
+		"10.0.0.0/33",
+		"10.0.0.1, team1.lan",
+	}
+
+	for _, iprange := range valid {
+		if err := ValidateIPRange(iprange); err != nil {
+			t.Errorf("ValidateIPRange(%q): %v", iprange, err)
+		}
+		if _, err := ParseIPSet(iprange); err != nil {
+			t.Errorf("ParseIPSet(%q): %v", iprange, err)
+		}
+	}
+	for _, iprange := range invalid {
+		if ValidateIPRange(iprange) == nil {
+			t.Errorf("ValidateIPRange(%q) accepted an invalid range", iprange)
+		}
+		if _, err := ParseIPSet(iprange); err == nil {
+			t.Errorf("ParseIPSet(%q) accepted an invalid range", iprange)
+		}
+	}
+}
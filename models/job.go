@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -8,19 +9,46 @@ import (
 )
 
 type Job struct {
-	gorm.Model  `json:"-"`
-	JID         string    `json:"jid" gorm:"uniqueIndex"`
-	Type        string    `json:"type"`
-	IPRange     string    `json:"iprange"`
-	Status      string    `json:"status"` // queued, running, complete, failed
-	Scanner     string    `json:"scanner"`
-	TID         string    `json:"tid" gorm:"column:t_id;index"`
-	TeamName    string    `json:"team_name"` // Denormalized for convenience
-	StartedAt   time.Time `json:"started_at"`
-	CompletedAt time.Time `json:"completed_at"`
-	HostsFound  int       `json:"hosts_found"`
-	PortsFound  int       `json:"ports_found"`
-	ErrorMsg    string    `json:"error_msg"`
+	gorm.Model     `json:"-"`
+	JID            string       `json:"jid" gorm:"uniqueIndex"`
+	Type           string       `json:"type"`
+	IPRange        string       `json:"iprange"`
+	Status         string       `json:"status"` // queued, running, complete, failed
+	Scanner        string       `json:"scanner"`
+	TID            string       `json:"tid" gorm:"column:t_id;index"`
+	TeamName       string       `json:"team_name"` // Denormalized for convenience
+	StartedAt      time.Time    `json:"started_at"`
+	CompletedAt    time.Time    `json:"completed_at"`
+	HostsFound     int          `json:"hosts_found"`
+	PortsFound     int          `json:"ports_found"`
+	ErrorMsg       string       `json:"error_msg"`
+	LeaseExpiresAt time.Time    `json:"lease_expires_at"` // running jobs are failed and requeued after this
+	LastHeartbeat  time.Time    `json:"last_heartbeat"`
+	Retries        int          `json:"retries"` // earlier failed attempts at this scan
+	ExitCode       int          `json:"exit_code"`
+	Stderr         string       `json:"stderr,omitempty"`
+	Profile        *ScanProfile `json:"profile,omitempty" gorm:"-"`      // set when handed to a scanner
+	CycleID        string       `json:"cycle_id,omitempty" gorm:"index"` // shared by the chunks of one team scan
+	ChunkIndex     int          `json:"chunk_index,omitempty"`           // 1-based
+	ChunkCount     int          `json:"chunk_count,omitempty"`
+	NewPorts       int          `json:"new_ports"`
+	MissingPorts   int          `json:"missing_ports"`
+	AdHoc          bool         `json:"ad_hoc" gorm:"index;default:false"` // queued on demand, handed out before scheduled work
+	Ports          string       `json:"ports,omitempty"`                   // ad hoc port list, replaces the job type's ports
+	RequestedBy    string       `json:"requested_by,omitempty"`
+	Exclude        []string     `json:"exclude,omitempty" gorm:"-"` // set when handed to a scanner
+	Percent        float64      `json:"percent"`                    // progress reported by the scanner
+	HostsDone      int          `json:"hosts_done"`
+	HostsTotal     int          `json:"hosts_total"`
+	ETA            time.Time    `json:"eta"`
+	ProgressAt     time.Time    `json:"progress_at"` // last progress report
+}
+
+// JobFailureRequest is sent by a scanner when a job could not be completed
+type JobFailureRequest struct {
+	Error    string `json:"error" binding:"required"`
+	ExitCode int    `json:"exit_code"`
+	Stderr   string `json:"stderr"`
 }
 
 func MakeJob(jobtype string, iprange string, tid string, teamName string) Job {
@@ -33,3 +61,17 @@ func MakeJob(jobtype string, iprange string, tid string, teamName string) Job {
 	job.Status = "queued"
 	return job
 }
+
+// AttachExclusions adds the exclusions for the job's team, and the matching
+// scanner argument, to a job being handed out
+func (j *Job) AttachExclusions() error {
+	exclusions, err := TeamExclusions(GetDB(), j.TID)
+	if err != nil {
+		return err
+	}
+	j.Exclude = exclusions.Targets
+	if j.Profile != nil && len(j.Exclude) > 0 {
+		j.Profile.Argv = append(j.Profile.Argv, "--exclude", strings.Join(j.Exclude, ","))
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// DefaultJobMaxRetries is how many times a failed scan is requeued
+const DefaultJobMaxRetries = 2
+
+// maxStderrBytes caps the scanner output kept with a failed job
+const maxStderrBytes = 8192
+
+// ErrJobNotRunning is returned when failing, or reporting progress on, a job
+// that already finished
+var ErrJobNotRunning = errors.New("job is not running")
+
+// JobMaxRetries returns the retry limit from JOB_MAX_RETRIES
+func JobMaxRetries() int {
+	if v := os.Getenv("JOB_MAX_RETRIES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			return n
+		}
+		fmt.Printf("Warning: invalid JOB_MAX_RETRIES %q, using %d\n", v, DefaultJobMaxRetries)
+	}
+	return DefaultJobMaxRetries
+}
+
+// FailJob marks a running job failed with msg, keeping the job's ExitCode
+// and the tail of its Stderr. If the retry policy allows, a fresh job for
+// the same team is queued and returned; otherwise the result is nil.
+func FailJob(job *Job, msg string) (*Job, error) {
+	if len(job.Stderr) > maxStderrBytes {
+		job.Stderr = job.Stderr[len(job.Stderr)-maxStderrBytes:]
+	}
+
+	// Only fail jobs still running, in case the scan was uploaded or reaped meanwhile
+	db := GetDB()
+	result := db.Model(&Job{}).Where("id = ? AND status = ?", job.ID, "running").Updates(map[string]interface{}{
+		"status":       "failed",
+		"completed_at": time.Now(),
+		"error_msg":    msg,
+		"exit_code":    job.ExitCode,
+		"stderr":       job.Stderr,
+	})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrJobNotRunning
+	}
+	job.Status = "failed"
+	job.ErrorMsg = msg
+	PublishJobState(*job)
+
+	if job.Retries >= JobMaxRetries() {
+		// The last chunk of a cycle may have just given up
+		if err := RecordScanCycle(db, *job); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+	retry := MakeJob(job.Type, job.IPRange, job.TID, job.TeamName)
+	retry.Retries = job.Retries + 1
+	retry.CycleID = job.CycleID
+	retry.ChunkIndex = job.ChunkIndex
+	retry.ChunkCount = job.ChunkCount
+	retry.AdHoc = job.AdHoc
+	retry.Ports = job.Ports
+	retry.RequestedBy = job.RequestedBy
+	if err := db.Create(&retry).Error; err != nil {
+		return nil, err
+	}
+	PublishJobState(retry)
+	NotifyWork()
+	return &retry, nil
+}
+
+// FailureRate summarises finished jobs for one team or scanner
+type FailureRate struct {
+	Key       string  `json:"key"` // team ID or scanner name
+	Name      string  `json:"name"`
+	Total     int     `json:"total"`
+	Failed    int     `json:"failed"`
+	Rate      float64 `json:"rate"`
+	LastError string  `json:"last_error,omitempty"`
+}
+
+// JobFailureRates returns failure rates of finished jobs grouped by column,
+// either "t_id" or "scanner"
+func JobFailureRates(column string, since time.Time) ([]FailureRate, error) {
+	var name string
+	switch column {
+	case "t_id":
+		name = "MAX(team_name)"
+	case "scanner":
+		name = "scanner"
+	default:
+		return nil, errors.New("unsupported grouping: " + column)
+	}
+
+	rates := []FailureRate{}
+	result := GetDB().Model(&Job{}).
+		Select(column+" AS key, "+name+" AS name, COUNT(*) AS total, SUM(CASE WHEN status = 'failed' THEN 1 ELSE 0 END) AS failed").
+		Where("status IN ? AND created_at >= ?", []string{"complete", "failed"}, since).
+		Group(column).Order("name ASC").Scan(&rates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	for i := range rates {
+		if rates[i].Total > 0 {
+			rates[i].Rate = float64(rates[i].Failed) / float64(rates[i].Total)
+		}
+		if rates[i].Failed > 0 {
+			var last Job
+			if GetDB().Where(column+" = ? AND status = ?", rates[i].Key, "failed").Order("completed_at DESC").First(&last).Error == nil {
+				rates[i].LastError = last.ErrorMsg
+			}
+		}
+	}
+	return rates, nil
+}
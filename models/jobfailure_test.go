@@ -0,0 +1,102 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFailJobRetries(t *testing.T) {
+	useTestDB(t)
+	t.Setenv("JOB_MAX_RETRIES", "2")
+
+	team := createTestTeam(t, "team1", "10.0.1.0/24")
+	job := MakeJob("nmap", "10.0.1.5", team.TID, team.Name)
+	job.Status = "running"
+	job.AdHoc = true
+	job.Ports = "22,443"
+	job.RequestedBy = "admin"
+	if err := GetDB().Create(&job).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Each failure queues a copy of the scan with one more retry, until the
+	// limit is used up
+	attempts := []Job{job}
+	for {
+		current := attempts[len(attempts)-1]
+		current.ExitCode = 1
+		retry, err := FailJob(&current, "nmap exited with status 1")
+		if err != nil {
+			t.Fatalf("attempt %d: %v", len(attempts), err)
+		}
+		if retry == nil {
+			break
+		}
+		if retry.Status != "queued" || retry.Retries != current.Retries+1 {
+			t.Errorf("retry %d is %s with %d retries", len(attempts), retry.Status, retry.Retries)
+		}
+		if retry.IPRange != job.IPRange || !retry.AdHoc || retry.Ports != job.Ports || retry.RequestedBy != job.RequestedBy {
+			t.Errorf("retry %d lost the ad hoc scan's target: %+v", len(attempts), *retry)
+		}
+		GetDB().Model(retry).Update("status", "running")
+		retry.Status = "running"
+		attempts = append(attempts, *retry)
+	}
+	if len(attempts) != 3 {
+		t.Fatalf("scan was attempted %d times, want 3", len(attempts))
+	}
+
+	for i, attempt := range attempts {
+		stored := reloadJob(t, attempt.JID)
+		if stored.Status != "failed" || stored.ExitCode != 1 || stored.ErrorMsg != "nmap exited with status 1" {
+			t.Errorf("attempt %d stored as %s, exit %d, %q", i+1, stored.Status, stored.ExitCode, stored.ErrorMsg)
+		}
+		if stored.CompletedAt.IsZero() {
+			t.Errorf("attempt %d has no completion time", i+1)
+		}
+	}
+}
+
+func TestFailJobNotRunning(t *testing.T) {
+	useTestDB(t)
+	team := createTestTeam(t, "team1", "10.0.1.0/24")
+
+	for _, status := range []string{"queued", "complete", "failed"} {
+		job := createTestJob(t, team, "nmap", status)
+		retry, err := FailJob(&job, "too late")
+		if !errors.Is(err, ErrJobNotRunning) || retry != nil {
+			t.Errorf("failing a %s job: retry %v, err %v", status, retry, err)
+		}
+		if stored := reloadJob(t, job.JID); stored.Status != status || stored.ErrorMsg != "" {
+			t.Errorf("%s job was changed to %s, %q", status, stored.Status, stored.ErrorMsg)
+		}
+	}
+}
+
+func TestFailJobKeepsStderrTail(t *testing.T) {
+	useTestDB(t)
+	t.Setenv("JOB_MAX_RETRIES", "0")
+	team := createTestTeam(t, "team1", "10.0.1.0/24")
+	job := createTestJob(t, team, "nmap", "running")
+
+	job.Stderr = strings.Repeat("x", maxStderrBytes) + "QUITTING!"
+	retry, err := FailJob(&job, "nmap crashed")
+	if err != nil || retry != nil {
+		t.Fatalf("FailJob = %v, %v; want no retry with JOB_MAX_RETRIES=0", retry, err)
+	}
+
+	stderr := reloadJob(t, job.JID).Stderr
+	if len(stderr) != maxStderrBytes || !strings.HasSuffix(stderr, "QUITTING!") {
+		t.Errorf("kept %d bytes of stderr, want the last %d", len(stderr), maxStderrBytes)
+	}
+}
+
+func TestJobMaxRetries(t *testing.T) {
+	for env, want := range map[string]int{"": DefaultJobMaxRetries, "0": 0, "5": 5, "-1": DefaultJobMaxRetries, "lots": DefaultJobMaxRetries} {
+		t.Setenv("JOB_MAX_RETRIES", env)
+		if got := JobMaxRetries(); got != want {
+			t.Errorf("JOB_MAX_RETRIES=%q gives %d, want %d", env, got, want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidCursor is returned by ListJobs for a cursor it did not issue
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// jobSortColumns maps the sort names accepted by the jobs API to columns
+var jobSortColumns = map[string]string{
+	"created":   "created_at",
+	"started":   "started_at",
+	"completed": "completed_at",
+}
+
+// JobFilter selects jobs for listing. Empty fields match everything.
+type JobFilter struct {
+	TeamID   string
+	Type     string
+	Scanner  string
+	Statuses []string
+	Since    time.Time // created at or after
+	Until    time.Time // created before
+}
+
+// JobPage selects one page of a job listing
+type JobPage struct {
+	Sort   string // created, started or completed
+	Desc   bool
+	Limit  int
+	Cursor string // from the previous page, empty for the first
+}
+
+// jobCursor marks the last job of a page: its sort value and row ID, so
+// pages stay stable while new jobs are added
+type jobCursor struct {
+	Value time.Time `json:"v"`
+	ID    uint      `json:"id"`
+}
+
+// ValidateJobSort checks a sort name for the jobs API
+func ValidateJobSort(sort string) error {
+	if _, ok := jobSortColumns[sort]; !ok {
+		return errors.New("sort must be created, started or completed")
+	}
+	return nil
+}
+
+func (f JobFilter) apply(tx *gorm.DB) *gorm.DB {
+	if f.TeamID != "" {
+		tx = tx.Where("t_id = ?", f.TeamID)
+	}
+	if f.Type != "" {
+		tx = tx.Where("type = ?", f.Type)
+	}
+	if f.Scanner != "" {
+		tx = tx.Where("scanner = ?", f.Scanner)
+	}
+	if len(f.Statuses) > 0 {
+		tx = tx.Where("status IN ?", f.Statuses)
+	}
+	if !f.Since.IsZero() {
+		tx = tx.Where("created_at >= ?", f.Since)
+	}
+	if !f.Until.IsZero() {
+		tx = tx.Where("created_at < ?", f.Until)
+	}
+	return tx
+}
+
+func sortValue(job Job, sort string) time.Time {
+	switch sort {
+	case "started":
+		return job.StartedAt
+	case "completed":
+		return job.CompletedAt
+	}
+	return job.CreatedAt
+}
+
+func encodeJobCursor(cur jobCursor) string {
+	data, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeJobCursor(s string) (jobCursor, error) {
+	var cur jobCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(data, &cur)
+	}
+	if err != nil {
+		return cur, ErrInvalidCursor
+	}
+	return cur, nil
+}
+
+// ListJobs returns one page of the jobs matching filter, the number of
+// matching jobs across all pages, and the cursor for the next page (empty
+// on the last page)
+func ListJobs(filter JobFilter, page JobPage) ([]Job, int64, string, error) {
+	column, ok := jobSortColumns[page.Sort]
+	if !ok {
+		return nil, 0, "", ValidateJobSort(page.Sort)
+	}
+
+	db := GetDB()
+	var total int64
+	if err := filter.apply(db.Model(&Job{})).Count(&total).Error; err != nil {
+		return nil, 0, "", err
+	}
+
+	dir, cmp := "ASC", ">"
+	if page.Desc {
+		dir, cmp = "DESC", "<"
+	}
+	query := filter.apply(db).Order(column + " " + dir).Order("id " + dir)
+	if page.Cursor != "" {
+		cur, err := decodeJobCursor(page.Cursor)
+		if err != nil {
+			return nil, 0, "", err
+		}
+		query = query.Where("(("+column+" "+cmp+" ?) OR ("+column+" = ? AND id "+cmp+" ?))", cur.Value, cur.Value, cur.ID)
+	}
+
+	// Fetch one extra row to tell whether there is another page
+	var jobs []Job
+	if err := query.Limit(page.Limit + 1).Find(&jobs).Error; err != nil {
+		return nil, 0, "", err
+	}
+
+	next := ""
+	if len(jobs) > page.Limit {
+		jobs = jobs[:page.Limit]
+		last := jobs[len(jobs)-1]
+		next = encodeJobCursor(jobCursor{Value: sortValue(last, page.Sort), ID: last.ID})
+	}
+	return jobs, total, next, nil
+}
@@ -0,0 +1,154 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"testing"
+	"time"
+)
+
+// pageThrough lists every page of a job listing and returns the job IDs in
+// the order they were served
+func pageThrough(t *testing.T, filter JobFilter, page JobPage) []uint {
+	t.Helper()
+	var ids []uint
+	for pages := 0; ; pages++ {
+		if pages > 20 {
+			t.Fatal("pagination does not end")
+		}
+		jobs, _, next, err := ListJobs(filter, page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(jobs) > page.Limit {
+			t.Fatalf("page of %d jobs, limit %d", len(jobs), page.Limit)
+		}
+		for _, job := range jobs {
+			ids = append(ids, job.ID)
+		}
+		if next == "" {
+			return ids
+		}
+		page.Cursor = next
+	}
+}
+
+func TestListJobsCursor(t *testing.T) {
+	useTestDB(t)
+	team := createTestTeam(t, "team1", "10.0.1.0/24")
+
+	// Several jobs share a creation time, and queued jobs have no start
+	// time at all, so pages have to break ties on the row ID
+	base := time.Date(2024, 5, 29, 16, 0, 0, 0, time.UTC)
+	offsets := []int{0, 5, 5, 5, 10, 15, 15, 20}
+	var jobs []Job
+	for i, minutes := range offsets {
+		status := "complete"
+		if i%3 == 0 {
+			status = "queued"
+		}
+		job := createTestJob(t, team, "nmap", status)
+		created := base.Add(time.Duration(minutes) * time.Minute)
+		update := map[string]interface{}{"created_at": created}
+		if status == "complete" {
+			update["started_at"] = created.Add(time.Minute)
+		}
+		if err := GetDB().Model(&job).Updates(update).Error; err != nil {
+			t.Fatal(err)
+		}
+		jobs = append(jobs, reloadJob(t, job.JID))
+	}
+
+	for _, sortBy := range []string{"created", "started"} {
+		for _, desc := range []bool{false, true} {
+			want := make([]Job, len(jobs))
+			copy(want, jobs)
+			sort.Slice(want, func(i, j int) bool {
+				a, b := sortValue(want[i], sortBy), sortValue(want[j], sortBy)
+				if !a.Equal(b) {
+					return a.Before(b) != desc
+				}
+				return (want[i].ID < want[j].ID) != desc
+			})
+
+			got := pageThrough(t, JobFilter{}, JobPage{Sort: sortBy, Desc: desc, Limit: 3})
+			if len(got) != len(want) {
+				t.Errorf("sort %s desc=%v: served %d jobs, want %d", sortBy, desc, len(got), len(want))
+				continue
+			}
+			for i := range want {
+				if got[i] != want[i].ID {
+					t.Errorf("sort %s desc=%v: served %v, want job %d at %d", sortBy, desc, got, want[i].ID, i)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestListJobsNewJobBetweenPages(t *testing.T) {
+	useTestDB(t)
+	team := createTestTeam(t, "team1", "10.0.1.0/24")
+	for i := 0; i < 4; i++ {
+		createTestJob(t, team, "nmap", "complete")
+	}
+
+	page := JobPage{Sort: "created", Desc: true, Limit: 2}
+	first, total, next, err := ListJobs(JobFilter{}, page)
+	if err != nil || total != 4 || next == "" {
+		t.Fatalf("first page: %d jobs of %d, next %q, err %v", len(first), total, next, err)
+	}
+
+	// A job created meanwhile sorts before the first page, so the next page
+	// carries on where the first stopped
+	createTestJob(t, team, "nmap", "queued")
+	page.Cursor = next
+	second, total, next, err := ListJobs(JobFilter{}, page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 5 || next != "" || len(second) != 2 {
+		t.Fatalf("second page: %d jobs of %d, next %q", len(second), total, next)
+	}
+	if second[0].ID >= first[1].ID {
+		t.Errorf("second page starts at job %d, after job %d", second[0].ID, first[1].ID)
+	}
+}
+
+func TestListJobsFilter(t *testing.T) {
+	useTestDB(t)
+	team1 := createTestTeam(t, "team1", "10.0.1.0/24")
+	team2 := createTestTeam(t, "team2", "10.0.2.0/24")
+	createTestJob(t, team1, "nmap", "complete")
+	createTestJob(t, team1, "nmap", "failed")
+	createTestJob(t, team1, "nuclei", "failed")
+	createTestJob(t, team2, "nmap", "failed")
+
+	filter := JobFilter{TeamID: team1.TID, Statuses: []string{"failed"}}
+	jobs, total, _, err := ListJobs(filter, JobPage{Sort: "created", Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 {
+		t.Errorf("total = %d, want the 2 failed team1 jobs", total)
+	}
+	if got := pageThrough(t, filter, JobPage{Sort: "created", Limit: 1}); len(got) != 2 || got[0] != jobs[0].ID {
+		t.Errorf("paged through %v", got)
+	}
+
+	filter.Type = "nuclei"
+	if _, total, _, _ := ListJobs(filter, JobPage{Sort: "created", Limit: 10}); total != 1 {
+		t.Errorf("with type nuclei: total = %d, want 1", total)
+	}
+}
+
+func TestListJobsRejectsBadInput(t *testing.T) {
+	useTestDB(t)
+
+	if _, _, _, err := ListJobs(JobFilter{}, JobPage{Sort: "created", Limit: 10, Cursor: "not-a-cursor"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("garbage cursor: err = %v", err)
+	}
+	if _, _, _, err := ListJobs(JobFilter{}, JobPage{Sort: "priority", Limit: 10}); err == nil {
+		t.Error("unknown sort was accepted")
+	}
+}
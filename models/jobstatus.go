@@ -2,24 +2,131 @@ package models
 
 import (
 	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// JobStatus is a registered job type: its scan profile and its place in
+// the team rotation
 type JobStatus struct {
 	gorm.Model
-	Name     string `json:"name" gorm:"uniqueIndex"`
-	JobIndex int    `json:"job_index"`
+	Name        string `json:"name" gorm:"uniqueIndex"`
+	JobIndex    int    `json:"job_index"`
+	ChunkSize   string `json:"chunk_size"`                          // split team ranges into sub-jobs: host count or prefix such as "/26"
+	Strategy    string `json:"strategy" gorm:"default:round-robin"` // how the next team is chosen, see Strategy constants
+	ScanProfile `gorm:"embedded"`
+	Queue       []QueueEntry `json:"queue,omitempty" gorm:"-"`
+}
+
+// ScanProfile holds the scanner settings for a job type. Empty fields leave
+// the scanner's own defaults in place.
+type ScanProfile struct {
+	Description string   `json:"description"`
+	Ports       string   `json:"ports"`     // nmap -p syntax, e.g. "22,80,443,8000-8100" or "U:53,T:1-1024"
+	TopPorts    int      `json:"top_ports"` // nmap --top-ports, used when ports is empty
+	Timing      string   `json:"timing"`    // nmap timing template, T0-T5
+	Scripts     string   `json:"scripts"`   // comma-separated NSE scripts or categories
+	Args        string   `json:"args"`      // extra scanner arguments, e.g. "-sU -sV"
+	Argv        []string `json:"argv" gorm:"-"`
+}
+
+var (
+	jobTypeNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+	portListPattern    = regexp.MustCompile(`^((U|T|S):)?\d+(-\d+)?(,((U|T|S):)?\d+(-\d+)?)*$`)
+	timingPattern      = regexp.MustCompile(`^T[0-5]$`)
+	scriptsPattern     = regexp.MustCompile(`^[A-Za-z0-9_.*-]+(,[A-Za-z0-9_.*-]+)*$`)
+)
+
+// ValidateJobTypeName checks a job type name is safe to use in URLs
+func ValidateJobTypeName(name string) error {
+	if !jobTypeNamePattern.MatchString(name) {
+		return errors.New("job type name must be lowercase letters, digits, '-' or '_'")
+	}
+	return nil
+}
+
+// Validate checks a profile's fields
+func (p ScanProfile) Validate() error {
+	if p.Ports != "" && !portListPattern.MatchString(strings.ReplaceAll(p.Ports, " ", "")) {
+		return errors.New("invalid port list: " + p.Ports)
+	}
+	if p.TopPorts < 0 || p.TopPorts > 65535 {
+		return errors.New("top_ports must be between 0 and 65535")
+	}
+	if p.Ports != "" && p.TopPorts > 0 {
+		return errors.New("set either ports or top_ports, not both")
+	}
+	if p.Timing != "" && !timingPattern.MatchString(p.Timing) {
+		return errors.New("timing must be a template from T0 to T5")
+	}
+	if p.Scripts != "" && !scriptsPattern.MatchString(strings.ReplaceAll(p.Scripts, " ", "")) {
+		return errors.New("invalid script list: " + p.Scripts)
+	}
+	if strings.ContainsAny(p.Args, ";|&`$<>\n") {
+		return errors.New("args may not contain shell metacharacters")
+	}
+	for _, arg := range strings.Fields(p.Args) {
+		if strings.HasPrefix(arg, "-o") || strings.HasPrefix(arg, "-i") {
+			return errors.New("args may not set scanner input or output: " + arg)
+		}
+	}
+	return nil
+}
+
+// CommandArgs builds the scanner arguments for the profile, excluding targets
+// and output options which the scanner adds itself
+func (p ScanProfile) CommandArgs() []string {
+	argv := []string{}
+	if p.Timing != "" {
+		argv = append(argv, "-"+p.Timing)
+	}
+	if p.Ports != "" {
+		argv = append(argv, "-p", strings.ReplaceAll(p.Ports, " ", ""))
+	} else if p.TopPorts > 0 {
+		argv = append(argv, "--top-ports", strconv.Itoa(p.TopPorts))
+	}
+	if p.Scripts != "" {
+		argv = append(argv, "--script", strings.ReplaceAll(p.Scripts, " ", ""))
+	}
+	return append(argv, strings.Fields(p.Args)...)
+}
+
+// JobTypeRequest for creating/updating job types via API
+type JobTypeRequest struct {
+	Name      string `json:"name"`
+	ChunkSize string `json:"chunk_size"`
+	Strategy  string `json:"strategy"`
+	ScanProfile
 }
 
 var jobMutex sync.Mutex
 
-func (js *JobStatus) GetNextJob() (Job, error) {
+// Errors from GetNextJob that last until the teams change
+var (
+	ErrNoTeams       = errors.New("no teams configured")
+	ErrNoRoutedTeams = errors.New("no teams are routed to scanner")
+)
+
+// GetNextJob picks the next due team the scanner can reach, using the job
+// type's strategy, and creates its job (or the first chunk of it)
+func (js *JobStatus) GetNextJob(scanner *Scanner) (Job, error) {
 	jobMutex.Lock()
 	defer jobMutex.Unlock()
 
+	// Another scanner may have moved the rotation on, or the type been
+	// edited, since the caller loaded it
 	db := GetDB()
+	if err := db.First(js, "id = ?", js.ID).Error; err != nil {
+		return Job{}, errors.New("database error loading job type")
+	}
+
 	var teams []Team
 	result := db.Order("name ASC").Find(&teams)
 	if result.Error != nil {
@@ -27,20 +134,159 @@ func (js *JobStatus) GetNextJob() (Job, error) {
 	}
 
 	if len(teams) == 0 {
-		return Job{}, errors.New("no teams configured")
+		return Job{}, ErrNoTeams
 	}
 
-	// Ensure index is within bounds
-	if js.JobIndex >= len(teams) {
-		js.JobIndex = 0
+	queue, err := js.teamQueue(teams, time.Now())
+	if err != nil {
+		return Job{}, err
 	}
 
-	team := teams[js.JobIndex]
+	// Take the first reachable team in the queue that is due and not blocked
+	// by a scan window, remembering the soonest one becomes ready in case
+	// none is
+	now := time.Now()
+	var picked *QueueEntry
+	var wait time.Duration
+	reachable, windowed := false, true
+	for i := range queue {
+		if !scanner.CanReach(queue[i].team) {
+			continue
+		}
+		reachable = true
+		windowed = windowed && queue[i].Blocked
+		ready := now
+		if queue[i].Blocked {
+			if queue[i].BlockedUntil == nil {
+				continue
+			}
+			ready = *queue[i].BlockedUntil
+		}
+		if queue[i].DueAt != nil && queue[i].DueAt.After(ready) {
+			ready = *queue[i].DueAt
+		}
+		if !ready.After(now) {
+			picked = &queue[i]
+			break
+		}
+		if wait == 0 || ready.Sub(now) < wait {
+			wait = ready.Sub(now)
+		}
+	}
+	if !reachable {
+		return Job{}, fmt.Errorf("%w %s", ErrNoRoutedTeams, scanner.Name)
+	}
+	if picked == nil {
+		if wait == 0 {
+			wait = blockedRetry
+		}
+		if windowed {
+			return Job{}, &NoWorkError{RetryAfter: wait, Reason: "every team is outside its scan windows"}
+		}
+		return Job{}, &NoWorkError{RetryAfter: wait}
+	}
+
+	team := picked.team
 	job := MakeJob(js.Name, team.IPRange, team.TID, team.Name)
+	job.Profile = js.Profile()
 
-	// Increment and wrap index
-	js.JobIndex = (js.JobIndex + 1) % len(teams)
-	db.Save(&js)
+	// Large ranges are split into chunks; the first is returned and the rest
+	// are queued so other scanners pick them up. A range that can't be split
+	// (the API refuses those, but the range or chunk size may predate that)
+	// is handed out whole.
+	chunks, err := js.chunkRange(team.IPRange)
+	if err != nil {
+		fmt.Printf("Warning: not splitting %s range %s for %s: %v\n", team.Name, team.IPRange, js.Name, err)
+	}
+	if len(chunks) > 1 {
+		job.CycleID = uuid.New().String()
+		job.IPRange = chunks[0]
+		job.ChunkIndex = 1
+		job.ChunkCount = len(chunks)
+		queued := make([]Job, 0, len(chunks)-1)
+		for i, chunk := range chunks[1:] {
+			q := MakeJob(js.Name, chunk, team.TID, team.Name)
+			q.CycleID = job.CycleID
+			q.ChunkIndex = i + 2
+			q.ChunkCount = len(chunks)
+			queued = append(queued, q)
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return tx.CreateInBatches(&queued, 100).Error
+		})
+		if err != nil {
+			return Job{}, errors.New("database error queueing chunks: " + err.Error())
+		}
+		for _, q := range queued {
+			PublishJobState(q)
+		}
+		NotifyWork()
+	}
+
+	// Round-robin continues after the picked team
+	for i := range teams {
+		if teams[i].TID == team.TID {
+			js.JobIndex = (i + 1) % len(teams)
+		}
+	}
+	db.Model(&JobStatus{}).Where("id = ?", js.ID).Update("job_index", js.JobIndex)
 
 	return job, nil
 }
+
+// Profile returns the job type's scan profile as handed to scanners
+func (js *JobStatus) Profile() *ScanProfile {
+	p := js.ScanProfile
+	p.Argv = p.CommandArgs()
+	return &p
+}
+
+// ProfileFor returns the profile for one job, with an ad hoc job's own port
+// list in place of the job type's
+func (js *JobStatus) ProfileFor(job Job) *ScanProfile {
+	p := js.ScanProfile
+	if job.Ports != "" {
+		p.Ports = job.Ports
+		p.TopPorts = 0
+	}
+	p.Argv = p.CommandArgs()
+	return &p
+}
+
+// Coverage returns the ports a scan with this profile probes. A port list
+// without a protocol prefix is TCP, as with nmap. Coverage is unknown with
+// top_ports or extra args, since args may change the ports or scan type.
+func (p ScanProfile) Coverage() PortCoverage {
+	if p.TopPorts > 0 || strings.TrimSpace(p.Args) != "" {
+		return PortCoverage{}
+	}
+	if p.Ports == "" {
+		return AllPorts
+	}
+	list, err := ParsePortList(p.Ports)
+	if err != nil {
+		return PortCoverage{}
+	}
+	// Without -sU, which needs args, nmap only scans the TCP entries
+	tcp := PortList{}
+	for _, r := range list {
+		if r.Protocol == "" || r.Protocol == "tcp" {
+			tcp = append(tcp, portRange{Protocol: "tcp", Low: r.Low, High: r.High})
+		}
+	}
+	return PortCoverage{Ports: tcp}
+}
+
+// chunkRange splits a team range by the job type's chunk size. It returns
+// nil when the type does not split ranges.
+func (js *JobStatus) chunkRange(iprange string) ([]string, error) {
+	size, err := ParseChunkSize(js.ChunkSize)
+	if err != nil || size == 0 {
+		return nil, err
+	}
+	set, err := ParseIPSet(iprange)
+	if err != nil {
+		return nil, err
+	}
+	return set.Chunks(size)
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// DefaultJobLease is how long a scanner may hold a job without a heartbeat
+const DefaultJobLease = 10 * time.Minute
+
+// jobReapInterval is how often expired leases are checked
+const jobReapInterval = 30 * time.Second
+
+// JobLeaseDuration returns the lease length from JOB_LEASE_SECONDS
+func JobLeaseDuration() time.Duration {
+	if v := os.Getenv("JOB_LEASE_SECONDS"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+			return time.Duration(secs) * time.Second
+		}
+		fmt.Printf("Warning: invalid JOB_LEASE_SECONDS %q, using %s\n", v, DefaultJobLease)
+	}
+	return DefaultJobLease
+}
+
+// ExtendLease records a heartbeat and pushes the lease out by a full period
+func (j *Job) ExtendLease() {
+	now := time.Now()
+	j.LastHeartbeat = now
+	j.LeaseExpiresAt = now.Add(JobLeaseDuration())
+}
+
+// ClaimQueuedJob hands out the oldest queued job of a type that scanner can
+// reach and whose team is not in a blackout, if any. Queued jobs are ad hoc
+// scans, requeued teams and chunks; they take priority over the scheduler,
+// with ad hoc scans first.
+func ClaimQueuedJob(jobtype string, scanner *Scanner) (Job, bool) {
+	db := GetDB()
+	var teams []Team
+	if err := db.Find(&teams).Error; err != nil {
+		return Job{}, false
+	}
+	blocks, err := WindowBlocks(db, teams, time.Now())
+	if err != nil {
+		return Job{}, false
+	}
+	skip := []string{}
+	for _, team := range teams {
+		if _, blocked := blocks[team.TID]; blocked || !scanner.CanReach(team) {
+			skip = append(skip, team.TID)
+		}
+	}
+
+	query := db.Where("type = ? AND status = ?", jobtype, "queued")
+	if len(skip) > 0 {
+		query = query.Where("t_id NOT IN ?", skip)
+	}
+	var candidates []Job
+	query.Order("ad_hoc DESC, id ASC").Limit(5).Find(&candidates)
+
+	for _, job := range candidates {
+		job.Status = "running"
+		job.Scanner = scanner.Name
+		job.StartedAt = time.Now()
+		job.ExtendLease()
+
+		// Another scanner may have claimed it first
+		result := db.Model(&Job{}).Where("id = ? AND status = ?", job.ID, "queued").Updates(map[string]interface{}{
+			"status":           job.Status,
+			"scanner":          job.Scanner,
+			"started_at":       job.StartedAt,
+			"lease_expires_at": job.LeaseExpiresAt,
+			"last_heartbeat":   job.LastHeartbeat,
+		})
+		if result.Error == nil && result.RowsAffected == 1 {
+			return job, true
+		}
+	}
+	return Job{}, false
+}
+
+// ReapExpiredJobs fails running jobs whose lease has expired and, within the
+// retry policy, queues a fresh job for the same team so another scanner
+// picks it up
+func ReapExpiredJobs() (int, error) {
+	db := GetDB()
+	var expired []Job
+	result := db.Where("status = ? AND lease_expires_at < ?", "running", time.Now()).Find(&expired)
+	if result.Error != nil {
+		return 0, errors.New("database error loading running jobs: " + result.Error.Error())
+	}
+
+	reaped := 0
+	for _, job := range expired {
+		msg := "lease expired"
+		if !job.LastHeartbeat.IsZero() {
+			msg += ", last heartbeat " + job.LastHeartbeat.Format(time.RFC3339)
+		}
+
+		if _, err := FailJob(&job, msg); err != nil {
+			continue
+		}
+		reaped++
+	}
+	return reaped, nil
+}
+
+// StartJobReaper runs ReapExpiredJobs in the background for the life of the process
+func StartJobReaper() {
+	go func() {
+		ticker := time.NewTicker(jobReapInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			n, err := ReapExpiredJobs()
+			if err != nil {
+				fmt.Printf("Warning: job reaper: %v\n", err)
+			} else if n > 0 {
+				fmt.Printf("Job reaper: failed %d jobs with expired leases\n", n)
+			}
+		}
+	}()
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// createTestTeam stores a team for tests that need one
+func createTestTeam(t *testing.T, name string, iprange string) Team {
+	t.Helper()
+	team := MakeTeam(name, iprange)
+	if err := GetDB().Create(&team).Error; err != nil {
+		t.Fatal(err)
+	}
+	return team
+}
+
+// createTestJob stores a job of the team in the given state
+func createTestJob(t *testing.T, team Team, jobtype string, status string) Job {
+	t.Helper()
+	job := MakeJob(jobtype, team.IPRange, team.TID, team.Name)
+	job.Status = status
+	if err := GetDB().Create(&job).Error; err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func reloadJob(t *testing.T, jid string) Job {
+	t.Helper()
+	var job Job
+	if err := GetDB().First(&job, "j_id = ?", jid).Error; err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func TestClaimQueuedJob(t *testing.T) {
+	useTestDB(t)
+	t.Setenv("JOB_LEASE_SECONDS", "300")
+
+	team := createTestTeam(t, "team1", "10.0.1.0/24")
+	first := createTestJob(t, team, "nmap", "queued")
+	second := createTestJob(t, team, "nmap", "queued")
+	createTestJob(t, team, "nuclei", "queued")
+	adhoc := MakeJob("nmap", "10.0.1.5", team.TID, team.Name)
+	adhoc.AdHoc = true
+	if err := GetDB().Create(&adhoc).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	scanner := &Scanner{Name: "agent-1"}
+	before := time.Now()
+	var order []string
+	for {
+		job, ok := ClaimQueuedJob("nmap", scanner)
+		if !ok {
+			break
+		}
+		order = append(order, job.JID)
+
+		stored := reloadJob(t, job.JID)
+		if stored.Status != "running" || stored.Scanner != "agent-1" {
+			t.Errorf("claimed job stored as %s by %q", stored.Status, stored.Scanner)
+		}
+		lease := stored.LeaseExpiresAt.Sub(before)
+		if lease < 5*time.Minute || lease > 5*time.Minute+time.Minute {
+			t.Errorf("lease expires %v after the claim, want about 5m", lease)
+		}
+	}
+
+	// Ad hoc scans go first, then the oldest queued job; other types stay put
+	want := []string{adhoc.JID, first.JID, second.JID}
+	if strings.Join(order, ",") != strings.Join(want, ",") {
+		t.Errorf("claimed %v, want %v", order, want)
+	}
+}
+
+func TestClaimQueuedJobRouting(t *testing.T) {
+	useTestDB(t)
+
+	dmz := createTestTeam(t, "dmz", "10.0.9.0/24")
+	dmz.ScannerTags = []string{"dmz"}
+	if err := GetDB().Model(&dmz).Select("scanner_tags").Updates(&dmz).Error; err != nil {
+		t.Fatal(err)
+	}
+	job := createTestJob(t, dmz, "nmap", "queued")
+
+	if _, ok := ClaimQueuedJob("nmap", &Scanner{Name: "inside"}); ok {
+		t.Fatal("a scanner without the team's tag was handed its job")
+	}
+	claimed, ok := ClaimQueuedJob("nmap", &Scanner{Name: "edge", Tags: []string{"dmz"}})
+	if !ok || claimed.JID != job.JID {
+		t.Fatalf("tagged scanner got %q, %v; want %q", claimed.JID, ok, job.JID)
+	}
+}
+
+func TestReapExpiredJobs(t *testing.T) {
+	useTestDB(t)
+	t.Setenv("JOB_MAX_RETRIES", "1")
+	db := GetDB()
+
+	team := createTestTeam(t, "team1", "10.0.1.0/24")
+	heartbeat := time.Now().Add(-20 * time.Minute)
+	stale := createTestJob(t, team, "nmap", "running")
+	db.Model(&stale).Updates(map[string]interface{}{
+		"scanner":          "agent-1",
+		"last_heartbeat":   heartbeat,
+		"lease_expires_at": time.Now().Add(-time.Minute),
+	})
+	healthy := createTestJob(t, team, "nmap", "running")
+	db.Model(&healthy).Update("lease_expires_at", time.Now().Add(time.Minute))
+
+	n, err := ReapExpiredJobs()
+	if err != nil || n != 1 {
+		t.Fatalf("ReapExpiredJobs = %d, %v; want 1", n, err)
+	}
+
+	reaped := reloadJob(t, stale.JID)
+	if reaped.Status != "failed" {
+		t.Errorf("expired job is %s, want failed", reaped.Status)
+	}
+	if want := "lease expired, last heartbeat " + heartbeat.Format(time.RFC3339); reaped.ErrorMsg != want {
+		t.Errorf("error = %q, want %q", reaped.ErrorMsg, want)
+	}
+	if got := reloadJob(t, healthy.JID); got.Status != "running" {
+		t.Errorf("job with a live lease is %s", got.Status)
+	}
+
+	// The team is requeued once, and the retry is not requeued again
+	var retry Job
+	if err := db.Where("t_id = ? AND status = ?", team.TID, "queued").First(&retry).Error; err != nil {
+		t.Fatalf("no retry queued: %v", err)
+	}
+	if retry.Retries != 1 || retry.IPRange != team.IPRange {
+		t.Errorf("retry = %d retries over %q", retry.Retries, retry.IPRange)
+	}
+	db.Model(&retry).Updates(map[string]interface{}{"status": "running", "lease_expires_at": time.Now().Add(-time.Second)})
+	if n, err := ReapExpiredJobs(); err != nil || n != 1 {
+		t.Fatalf("second ReapExpiredJobs = %d, %v; want 1", n, err)
+	}
+	var queued int64
+	db.Model(&Job{}).Where("status = ?", "queued").Count(&queued)
+	if queued != 0 {
+		t.Errorf("%d jobs queued after the last retry expired, want 0", queued)
+	}
+
+	// Nothing is left to reap
+	if n, _ := ReapExpiredJobs(); n != 0 {
+		t.Errorf("reaped %d jobs twice", n)
+	}
+}
@@ -0,0 +1,175 @@
+package models
+
+import (
+	"encoding/xml"
+	"errors"
+	"strings"
+	"time"
+)
+
+// nmap -oX document structs - only the fields RedBoard stores are mapped
+type nmapRun struct {
+	XMLName  xml.Name     `xml:"nmaprun"`
+	Start    int64        `xml:"start,attr"`
+	Hosts    []nmapHost   `xml:"host"`
+	RunStats nmapRunStats `xml:"runstats"`
+}
+
+type nmapRunStats struct {
+	Finished struct {
+		Time int64  `xml:"time,attr"`
+		Exit string `xml:"exit,attr"`
+	} `xml:"finished"`
+}
+
+type nmapHost struct {
+	Status struct {
+		State string `xml:"state,attr"`
+	} `xml:"status"`
+	Addresses []struct {
+		Addr     string `xml:"addr,attr"`
+		AddrType string `xml:"addrtype,attr"`
+	} `xml:"address"`
+	Hostnames []struct {
+		Name string `xml:"name,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"hostnames>hostname"`
+	Ports       []nmapPort   `xml:"ports>port"`
+	OSMatches   []nmapOS     `xml:"os>osmatch"`
+	HostScripts []nmapScript `xml:"hostscript>script"`
+}
+
+type nmapPort struct {
+	Protocol string `xml:"protocol,attr"`
+	PortID   uint16 `xml:"portid,attr"`
+	State    struct {
+		State string `xml:"state,attr"`
+	} `xml:"state"`
+	Service struct {
+		Name      string `xml:"name,attr"`
+		Product   string `xml:"product,attr"`
+		Version   string `xml:"version,attr"`
+		ExtraInfo string `xml:"extrainfo,attr"`
+		Tunnel    string `xml:"tunnel,attr"`
+	} `xml:"service"`
+	Scripts []nmapScript `xml:"script"`
+}
+
+type nmapOS struct {
+	Name     string `xml:"name,attr"`
+	Accuracy int    `xml:"accuracy,attr"`
+}
+
+type nmapScript struct {
+	ID     string `xml:"id,attr"`
+	Output string `xml:"output,attr"`
+}
+
+// ParseNmapXML converts native nmap XML output (nmap -oX) into a Scan.
+// Only hosts that are up and ports that are open are kept, matching what
+// the JSON agents upload.
+func ParseNmapXML(data []byte) (Scan, error) {
+	var run nmapRun
+	if err := xml.Unmarshal(data, &run); err != nil {
+		return Scan{}, errors.New("invalid nmap XML: " + err.Error())
+	}
+
+	scan := Scan{
+		Status: run.RunStats.Finished.Exit,
+		Hosts:  []ScanHost{},
+	}
+	if run.Start > 0 {
+		scan.StartTime = time.Unix(run.Start, 0)
+	}
+	if run.RunStats.Finished.Time > 0 {
+		scan.EndTime = time.Unix(run.RunStats.Finished.Time, 0)
+	}
+
+	for _, h := range run.Hosts {
+		if h.Status.State != "" && h.Status.State != "up" {
+			continue
+		}
+
+		host := ScanHost{Status: "up", Ports: []ScanPort{}}
+		for _, addr := range h.Addresses {
+			if addr.AddrType == "ipv4" || addr.AddrType == "ipv6" {
+				host.IP = addr.Addr
+				break
+			}
+		}
+		if host.IP == "" {
+			continue
+		}
+
+		// Prefer a user supplied name over the reverse lookup
+		for _, hn := range h.Hostnames {
+			if host.Hostname == "" || hn.Type == "user" {
+				host.Hostname = hn.Name
+			}
+		}
+
+		// Pick the most accurate OS guess
+		bestAccuracy := -1
+		for _, osm := range h.OSMatches {
+			if osm.Accuracy > bestAccuracy {
+				host.OS = osm.Name
+				bestAccuracy = osm.Accuracy
+			}
+		}
+
+		for _, p := range h.Ports {
+			if p.State.State != "open" {
+				continue
+			}
+			port := ScanPort{
+				Number:   p.PortID,
+				State:    p.State.State,
+				Protocol: p.Protocol,
+				Service:  p.Service.Name,
+				Version:  formatNmapVersion(p.Service.Product, p.Service.Version, p.Service.ExtraInfo),
+				Scripts:  convertNmapScripts(p.Scripts),
+			}
+			if p.Service.Tunnel != "" && port.Service != "" {
+				port.Service = p.Service.Tunnel + "/" + port.Service
+			}
+			host.Ports = append(host.Ports, port)
+		}
+
+		// Script results are stored per port, so host scripts (smb-vuln-*,
+		// etc.) are attached to the first open port of the host
+		if len(h.HostScripts) > 0 && len(host.Ports) > 0 {
+			host.Ports[0].Scripts = append(host.Ports[0].Scripts, convertNmapScripts(h.HostScripts)...)
+		}
+
+		scan.Hosts = append(scan.Hosts, host)
+	}
+
+	return scan, nil
+}
+
+// formatNmapVersion builds the version string the way nmap prints it,
+// e.g. "OpenSSH 8.9p1 (Ubuntu Linux; protocol 2.0)"
+func formatNmapVersion(product, version, extra string) string {
+	parts := []string{}
+	if product != "" {
+		parts = append(parts, product)
+	}
+	if version != "" {
+		parts = append(parts, version)
+	}
+	if extra != "" {
+		parts = append(parts, "("+extra+")")
+	}
+	return strings.Join(parts, " ")
+}
+
+func convertNmapScripts(scripts []nmapScript) []ScanScriptResult {
+	var results []ScanScriptResult
+	for _, s := range scripts {
+		results = append(results, ScanScriptResult{
+			Name:   s.ID,
+			Output: strings.TrimSpace(s.Output),
+		})
+	}
+	return results
+}
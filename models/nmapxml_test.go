@@ -0,0 +1,139 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// nmapSample is trimmed from nmap 7.94 output of
+// nmap -sV -sC -O -oX - 10.0.1.5 10.0.1.6 10.0.1.7
+const nmapSample = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE nmaprun>
+<nmaprun scanner="nmap" args="nmap -sV -sC -O -oX - 10.0.1.5 10.0.1.6 10.0.1.7" start="1717000000" startstr="Wed May 29 16:26:40 2024" version="7.94" xmloutputversion="1.05">
+<scaninfo type="syn" protocol="tcp" numservices="1000" services="1,3-4,6-7,9,13"/>
+<verbose level="0"/>
+<debugging level="0"/>
+<host starttime="1717000001" endtime="1717000042"><status state="up" reason="arp-response" reason_ttl="0"/>
+<address addr="10.0.1.5" addrtype="ipv4"/>
+<address addr="52:54:00:12:34:56" addrtype="mac" vendor="QEMU virtual NIC"/>
+<hostnames>
+<hostname name="web01.team1.lan" type="PTR"/>
+<hostname name="web01" type="user"/>
+</hostnames>
+<ports><extraports state="closed" count="996">
+<extrareasons reason="reset" count="996" proto="tcp" ports="1,3-4,6-7"/>
+</extraports>
+<port protocol="tcp" portid="22"><state state="open" reason="syn-ack" reason_ttl="64"/><service name="ssh" product="OpenSSH" version="8.9p1 Ubuntu 3ubuntu0.6" extrainfo="Ubuntu Linux; protocol 2.0" ostype="Linux" method="probed" conf="10"><cpe>cpe:/a:openbsd:openssh:8.9p1</cpe></service><script id="ssh-hostkey" output="&#xa;  256 aa:bb:cc:dd (ECDSA)&#xa;"/></port>
+<port protocol="tcp" portid="443"><state state="open" reason="syn-ack" reason_ttl="64"/><service name="http" product="nginx" version="1.18.0" tunnel="ssl" method="probed" conf="10"/><script id="http-title" output="Welcome to nginx!"/></port>
+<port protocol="tcp" portid="8080"><state state="filtered" reason="no-response" reason_ttl="0"/><service name="http-proxy" method="table" conf="3"/></port>
+</ports>
+<os><osmatch name="Linux 5.0 - 5.4" accuracy="95" line="67000"/><osmatch name="Linux 4.15 - 5.8" accuracy="98" line="64000"/></os>
+<hostscript><script id="smb2-time" output="&#xa;  date: 2024-05-29T16:27:20&#xa;"/></hostscript>
+</host>
+<host starttime="1717000001" endtime="1717000042"><status state="down" reason="no-response" reason_ttl="0"/>
+<address addr="10.0.1.6" addrtype="ipv4"/>
+</host>
+<host starttime="1717000001" endtime="1717000042"><status state="up" reason="echo-reply" reason_ttl="128"/>
+<address addr="10.0.1.7" addrtype="ipv4"/>
+<hostnames/>
+<ports><extraports state="filtered" count="1000"/></ports>
+</host>
+<runstats><finished time="1717000045" timestr="Wed May 29 16:27:25 2024" summary="Nmap done at Wed May 29 16:27:25 2024; 3 IP addresses (2 hosts up) scanned in 45.00 seconds" elapsed="45.00" exit="success"/><hosts up="2" down="1" total="3"/>
+</runstats>
+</nmaprun>
+`
+
+func TestParseNmapXML(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []ScanHost
+		wantErr string
+	}{
+		{
+			name: "service scan",
+			data: nmapSample,
+			want: []ScanHost{
+				{
+					IP:       "10.0.1.5",
+					Hostname: "web01",
+					OS:       "Linux 4.15 - 5.8",
+					Status:   "up",
+					Ports: []ScanPort{
+						{
+							Number: 22, State: "open", Protocol: "tcp", Service: "ssh",
+							Version: "OpenSSH 8.9p1 Ubuntu 3ubuntu0.6 (Ubuntu Linux; protocol 2.0)",
+							Scripts: []ScanScriptResult{
+								{Name: "ssh-hostkey", Output: "256 aa:bb:cc:dd (ECDSA)"},
+								{Name: "smb2-time", Output: "date: 2024-05-29T16:27:20"},
+							},
+						},
+						{
+							Number: 443, State: "open", Protocol: "tcp", Service: "ssl/http",
+							Version: "nginx 1.18.0",
+							Scripts: []ScanScriptResult{{Name: "http-title", Output: "Welcome to nginx!"}},
+						},
+					},
+				},
+				{IP: "10.0.1.7", Status: "up", Ports: []ScanPort{}},
+			},
+		},
+		{
+			name: "no hosts up",
+			data: `<?xml version="1.0" encoding="UTF-8"?>
+<nmaprun scanner="nmap" args="nmap -sn 10.0.9.0/30" start="1717000000" version="7.94" xmloutputversion="1.05">
+<runstats><finished time="1717000003" exit="success"/><hosts up="0" down="4" total="4"/></runstats>
+</nmaprun>`,
+			want: []ScanHost{},
+		},
+		{
+			name:    "truncated",
+			data:    nmapSample[:600],
+			wantErr: "invalid nmap XML",
+		},
+		{
+			name:    "not nmap",
+			data:    `<report><host/></report>`,
+			wantErr: "invalid nmap XML",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scan, err := ParseNmapXML([]byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(scan.Hosts, tt.want) {
+				t.Errorf("hosts = %+v\nwant %+v", scan.Hosts, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNmapXMLTimes(t *testing.T) {
+	scan, err := ParseNmapXML([]byte(nmapSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scan.Status != "success" {
+		t.Errorf("status = %q, want success", scan.Status)
+	}
+	if !scan.StartTime.Equal(time.Unix(1717000000, 0)) {
+		t.Errorf("start = %v", scan.StartTime)
+	}
+	if !scan.EndTime.Equal(time.Unix(1717000045, 0)) {
+		t.Errorf("end = %v", scan.EndTime)
+	}
+	if scan.PortsOnly {
+		t.Error("nmap service scans should not be ports only")
+	}
+}
@@ -0,0 +1,160 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+type nucleiRecord struct {
+	TemplateID string `json:"template-id"`
+	Info       struct {
+		Name        string `json:"name"`
+		Severity    string `json:"severity"`
+		Description string `json:"description"`
+	} `json:"info"`
+	Type             string          `json:"type"`
+	Host             string          `json:"host"`
+	IP               string          `json:"ip"`
+	Port             json.RawMessage `json:"port"`
+	Scheme           string          `json:"scheme"`
+	MatchedAt        string          `json:"matched-at"`
+	MatcherName      string          `json:"matcher-name"`
+	ExtractedResults []string        `json:"extracted-results"`
+}
+
+// ParseNuclei parses nuclei JSON lines output (-jsonl / -json). Each result is
+// attached to the port it matched on; the port is taken from the record,
+// then from the matched URL, then from the scheme's default port.
+func ParseNuclei(data []byte) (Scan, error) {
+	b := newPortScanBuilder()
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var r nucleiRecord
+		if err := json.Unmarshal(line, &r); err != nil {
+			return Scan{}, errors.New("invalid nuclei JSON on line " + strconv.Itoa(lineNum) + ": " + err.Error())
+		}
+
+		ip, number := nucleiTarget(r)
+		if ip == "" || number == 0 {
+			continue
+		}
+
+		protocol := "tcp"
+		if r.Type == "dns" {
+			protocol = "udp"
+		}
+		b.addPort(ip, number, protocol, "")
+
+		name := r.Info.Name
+		if r.MatcherName != "" {
+			name += " [" + r.MatcherName + "]"
+		}
+		severity := strings.ToLower(r.Info.Severity)
+		if severity == "" || severity == "unknown" {
+			severity = SeverityInfo
+		}
+
+		h := b.host(ip)
+		for i := range h.Ports {
+			if h.Ports[i].Number == number && h.Ports[i].Protocol == protocol {
+				h.Ports[i].Findings = append(h.Ports[i].Findings, ScanFinding{
+					Source:      "nuclei",
+					TemplateID:  r.TemplateID,
+					Name:        name,
+					Severity:    severity,
+					MatchedAt:   r.MatchedAt,
+					Extracted:   r.ExtractedResults,
+					Description: strings.TrimSpace(r.Info.Description),
+				})
+				break
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Scan{}, errors.New("unable to read nuclei output: " + err.Error())
+	}
+
+	return b.scan(), nil
+}
+
+// nucleiTarget works out the IP and port a nuclei result applies to
+func nucleiTarget(r nucleiRecord) (string, uint16) {
+	ip := r.IP
+	var number uint16
+
+	// Newer nuclei versions report the port as a string, older ones as a number
+	var portStr string
+	if err := json.Unmarshal(r.Port, &portStr); err != nil {
+		var n uint16
+		if json.Unmarshal(r.Port, &n) == nil {
+			number = n
+		}
+	} else if n, err := strconv.ParseUint(portStr, 10, 16); err == nil {
+		number = uint16(n)
+	}
+
+	for _, target := range []string{r.MatchedAt, r.Host} {
+		if target == "" || (ip != "" && number != 0) {
+			continue
+		}
+		host, port, scheme := splitNucleiTarget(target)
+		if ip == "" && net.ParseIP(host) != nil {
+			ip = host
+		}
+		if number == 0 {
+			number = port
+			if number == 0 {
+				number = defaultSchemePort(scheme)
+			}
+		}
+	}
+	if number == 0 {
+		number = defaultSchemePort(r.Scheme)
+	}
+
+	return ip, number
+}
+
+// splitNucleiTarget handles both URLs ("https://10.0.0.5:8443/path") and
+// bare host:port targets ("10.0.0.5:22")
+func splitNucleiTarget(target string) (string, uint16, string) {
+	if strings.Contains(target, "://") {
+		u, err := url.Parse(target)
+		if err != nil {
+			return "", 0, ""
+		}
+		n, _ := strconv.ParseUint(u.Port(), 10, 16)
+		return u.Hostname(), uint16(n), u.Scheme
+	}
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return target, 0, ""
+	}
+	n, _ := strconv.ParseUint(port, 10, 16)
+	return host, uint16(n), ""
+}
+
+func defaultSchemePort(scheme string) uint16 {
+	switch strings.ToLower(scheme) {
+	case "http":
+		return 80
+	case "https":
+		return 443
+	}
+	return 0
+}
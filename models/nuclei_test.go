@@ -0,0 +1,124 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseNuclei(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []ScanHost
+		wantErr string
+	}{
+		{
+			name: "nuclei v3 jsonl",
+			data: `{"template":"http/misconfiguration/http-missing-security-headers.yaml","template-id":"http-missing-security-headers","template-path":"/root/nuclei-templates/http/misconfiguration/http-missing-security-headers.yaml","info":{"name":"HTTP Missing Security Headers","author":["socketz","geeknik"],"tags":["misconfig","headers","generic"],"description":"This template searches for missing HTTP security headers.\n","severity":"info"},"matcher-name":"strict-transport-security","type":"http","host":"https://10.0.1.5:8443","port":"8443","scheme":"https","url":"https://10.0.1.5:8443","matched-at":"https://10.0.1.5:8443","ip":"10.0.1.5","timestamp":"2024-05-29T16:30:01.123456789Z","matcher-status":true}
+{"template-id":"CVE-2021-41773","info":{"name":"Apache 2.4.49 - Path Traversal","severity":"critical","description":"A path traversal flaw in Apache HTTP Server 2.4.49."},"type":"http","host":"http://10.0.1.5","matched-at":"http://10.0.1.5/cgi-bin/.%2e/.%2e/etc/passwd","ip":"10.0.1.5","timestamp":"2024-05-29T16:30:02Z","matcher-status":true}
+{"template-id":"openssh-detect","info":{"name":"OpenSSH Service - Detect","severity":"info"},"type":"tcp","host":"10.0.1.6:22","port":22,"matched-at":"10.0.1.6:22","extracted-results":["SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6"],"ip":"10.0.1.6","timestamp":"2024-05-29T16:30:03Z"}
+
+{"template-id":"dns-zone-transfer","info":{"name":"DNS Zone Transfer","severity":"unknown"},"type":"dns","host":"10.0.1.7:53","matched-at":"10.0.1.7:53","timestamp":"2024-05-29T16:30:04Z"}
+{"template-id":"tech-detect","info":{"name":"Wappalyzer Technology Detection","severity":"info"},"type":"http","host":"https://intranet.team1.lan","matched-at":"https://intranet.team1.lan/","timestamp":"2024-05-29T16:30:05Z"}
+`,
+			want: []ScanHost{
+				{IP: "10.0.1.5", Status: "up", Ports: []ScanPort{
+					{Number: 8443, State: "open", Protocol: "tcp", Findings: []ScanFinding{{
+						Source:      "nuclei",
+						TemplateID:  "http-missing-security-headers",
+						Name:        "HTTP Missing Security Headers [strict-transport-security]",
+						Severity:    SeverityInfo,
+						MatchedAt:   "https://10.0.1.5:8443",
+						Description: "This template searches for missing HTTP security headers.",
+					}}},
+					{Number: 80, State: "open", Protocol: "tcp", Findings: []ScanFinding{{
+						Source:      "nuclei",
+						TemplateID:  "CVE-2021-41773",
+						Name:        "Apache 2.4.49 - Path Traversal",
+						Severity:    SeverityCritical,
+						MatchedAt:   "http://10.0.1.5/cgi-bin/.%2e/.%2e/etc/passwd",
+						Description: "A path traversal flaw in Apache HTTP Server 2.4.49.",
+					}}},
+				}},
+				{IP: "10.0.1.6", Status: "up", Ports: []ScanPort{
+					{Number: 22, State: "open", Protocol: "tcp", Findings: []ScanFinding{{
+						Source:     "nuclei",
+						TemplateID: "openssh-detect",
+						Name:       "OpenSSH Service - Detect",
+						Severity:   SeverityInfo,
+						MatchedAt:  "10.0.1.6:22",
+						Extracted:  []string{"SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6"},
+					}}},
+				}},
+				{IP: "10.0.1.7", Status: "up", Ports: []ScanPort{
+					{Number: 53, State: "open", Protocol: "udp", Findings: []ScanFinding{{
+						Source:     "nuclei",
+						TemplateID: "dns-zone-transfer",
+						Name:       "DNS Zone Transfer",
+						Severity:   SeverityInfo,
+						MatchedAt:  "10.0.1.7:53",
+					}}},
+				}},
+			},
+		},
+		{
+			name:    "text output",
+			data:    "[http-missing-security-headers:strict-transport-security] [http] [info] https://10.0.1.5:8443\n",
+			wantErr: "invalid nuclei JSON on line 1",
+		},
+		{
+			name: "empty",
+			data: "",
+			want: []ScanHost{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scan, err := ParseNuclei([]byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(scan.Hosts, tt.want) {
+				t.Errorf("hosts = %+v\nwant %+v", scan.Hosts, tt.want)
+			}
+		})
+	}
+}
+
+func TestNucleiTarget(t *testing.T) {
+	tests := []struct {
+		name     string
+		record   string
+		wantIP   string
+		wantPort uint16
+	}{
+		{"string port", `{"ip":"10.0.1.5","port":"8443"}`, "10.0.1.5", 8443},
+		{"numeric port", `{"ip":"10.0.1.5","port":22}`, "10.0.1.5", 22},
+		{"port from matched url", `{"matched-at":"https://10.0.1.5:9443/login"}`, "10.0.1.5", 9443},
+		{"port from scheme", `{"ip":"10.0.1.5","matched-at":"https://web01.team1.lan/"}`, "10.0.1.5", 443},
+		{"port from record scheme", `{"ip":"10.0.1.5","scheme":"http"}`, "10.0.1.5", 80},
+		{"bare host port", `{"host":"10.0.1.6:3306"}`, "10.0.1.6", 3306},
+		{"hostname only", `{"host":"https://intranet.team1.lan"}`, "", 443},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r nucleiRecord
+			if err := json.Unmarshal([]byte(tt.record), &r); err != nil {
+				t.Fatal(err)
+			}
+			ip, port := nucleiTarget(r)
+			if ip != tt.wantIP || port != tt.wantPort {
+				t.Errorf("nucleiTarget = %q, %d; want %q, %d", ip, port, tt.wantIP, tt.wantPort)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Queue pause actions
+const (
+	QueuePause  = "pause"
+	QueueResume = "resume"
+)
+
+// QueueEvent records a manual pause or resume of job handout. The latest
+// event gives the current state, so the table doubles as the audit log.
+type QueueEvent struct {
+	gorm.Model `json:"-"`
+	Action     string    `json:"action"`
+	User       string    `json:"user"`
+	Reason     string    `json:"reason"`
+	Time       time.Time `json:"time" gorm:"index"`
+}
+
+// QueueEventRequest carries an optional reason for pausing or resuming
+type QueueEventRequest struct {
+	Reason string `json:"reason"`
+}
+
+// LastQueueEvent returns the most recent pause or resume, if any
+func LastQueueEvent(tx *gorm.DB) (QueueEvent, bool, error) {
+	var event QueueEvent
+	err := tx.Order("id DESC").First(&event).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return event, false, nil
+	}
+	return event, err == nil, err
+}
+
+// QueuePaused reports whether job handout is manually paused
+func QueuePaused() (bool, error) {
+	event, ok, err := LastQueueEvent(GetDB())
+	if err != nil {
+		return false, err
+	}
+	return ok && event.Action == QueuePause, nil
+}
+
+// CheckQueuePaused returns a NoWorkError while job handout is paused
+func CheckQueuePaused() error {
+	paused, err := QueuePaused()
+	if err != nil {
+		return err
+	}
+	if paused {
+		return &NoWorkError{RetryAfter: blockedRetry, Reason: "job handout is paused"}
+	}
+	return nil
+}
+
+// RecordQueueEvent pauses or resumes job handout on behalf of user
+func RecordQueueEvent(action string, user string, reason string) (QueueEvent, error) {
+	event := QueueEvent{Action: action, User: user, Reason: reason, Time: time.Now()}
+	err := GetDB().Create(&event).Error
+	return event, err
+}
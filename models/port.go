@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
@@ -9,6 +11,33 @@ type ScriptResult struct {
 	PortID     uint   `json:"port_id" gorm:"index"`
 	Name       string `json:"name"`
 	Output     string `json:"output" gorm:"type:text"`
+	Severity   string `json:"severity,omitempty"` // from the scanner, empty for NSE output
+	Source     string `json:"source,omitempty"`   // nessus, openvas; empty for nmap
+}
+
+// HostScript is a script result or report finding about a host as a whole
+// rather than one of its ports, such as a Nessus "general" item
+type HostScript struct {
+	gorm.Model `json:"-"`
+	HostID     uint   `json:"host_id" gorm:"index"`
+	Name       string `json:"name"`
+	Output     string `json:"output" gorm:"type:text"`
+	Severity   string `json:"severity,omitempty"`
+	Source     string `json:"source,omitempty"`
+}
+
+// Finding is a templated check result (e.g. nuclei) attached to a port
+type Finding struct {
+	gorm.Model  `json:"-"`
+	PortID      uint      `json:"port_id" gorm:"index"`
+	Source      string    `json:"source"`
+	TemplateID  string    `json:"template_id"`
+	Name        string    `json:"name"`
+	Severity    string    `json:"severity"`
+	MatchedAt   string    `json:"matched_at"`
+	Extracted   string    `json:"extracted" gorm:"type:text"` // newline separated
+	Description string    `json:"description" gorm:"type:text"`
+	LastSeen    time.Time `json:"last_seen"`
 }
 
 type Port struct {
@@ -21,7 +50,10 @@ type Port struct {
 	HostID     uint           `json:"host_id" gorm:"index"`
 	IsBaseline bool           `json:"is_baseline"`
 	IsNew      bool           `json:"is_new"`
+	FirstSeen  time.Time      `json:"first_seen"`
+	LastSeen   time.Time      `json:"last_seen"`
 	Scripts    []ScriptResult `json:"scripts,omitempty" gorm:"foreignKey:PortID;constraint:OnDelete:CASCADE"`
+	Findings   []Finding      `json:"findings,omitempty" gorm:"foreignKey:PortID;constraint:OnDelete:CASCADE"`
 }
 
 // Common dangerous ports for highlighting (removed DNS, SSH, HTTP-Proxy as they're expected)
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Port event types
+const (
+	PortEventOpened         = "opened"
+	PortEventClosed         = "closed"
+	PortEventServiceChanged = "service_changed"
+	PortEventVersionChanged = "version_changed"
+)
+
+// PortEvent records a change to a port seen by a scan
+type PortEvent struct {
+	gorm.Model `json:"-"`
+	TeamID     string    `json:"team_id" gorm:"index"`
+	HostID     uint      `json:"-" gorm:"index"`
+	HostIP     string    `json:"host_ip" gorm:"index"`
+	PortID     uint      `json:"-"`
+	Number     uint16    `json:"port"`
+	Protocol   string    `json:"protocol"`
+	Event      string    `json:"event"`
+	OldValue   string    `json:"old_value,omitempty"`
+	NewValue   string    `json:"new_value,omitempty"`
+	JID        string    `json:"jid,omitempty" gorm:"column:j_id"`
+	Time       time.Time `json:"time" gorm:"index"`
+}
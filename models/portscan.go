@@ -0,0 +1,314 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported upload formats for scan results
+const (
+	ScanFormatJSON        = "json"         // RedBoard agent JSON (models.Scan)
+	ScanFormatNmapXML     = "nmap-xml"     // nmap -oX
+	ScanFormatMasscanJSON = "masscan-json" // masscan -oJ
+	ScanFormatMasscanList = "masscan-list" // masscan -oL
+	ScanFormatNaabu       = "naabu"        // naabu -json (JSON lines)
+	ScanFormatRustscan    = "rustscan"     // rustscan -g
+	ScanFormatNessus      = "nessus"       // Nessus v2 XML (.nessus)
+	ScanFormatOpenVAS     = "openvas"      // OpenVAS / GVM XML report
+	ScanFormatNuclei      = "nuclei"       // nuclei -jsonl
+)
+
+// ParseScan converts raw scanner output in the given format into a Scan
+func ParseScan(format string, data []byte) (Scan, error) {
+	switch format {
+	case ScanFormatJSON:
+		var scan Scan
+		err := json.Unmarshal(data, &scan)
+		return scan, err
+	case ScanFormatNmapXML:
+		return ParseNmapXML(data)
+	case ScanFormatMasscanJSON:
+		return ParseMasscanJSON(data)
+	case ScanFormatMasscanList:
+		return ParseMasscanList(data)
+	case ScanFormatNaabu:
+		return ParseNaabu(data)
+	case ScanFormatRustscan:
+		return ParseRustscan(data)
+	case ScanFormatNessus:
+		return ParseNessus(data)
+	case ScanFormatOpenVAS:
+		return ParseOpenVAS(data)
+	case ScanFormatNuclei:
+		return ParseNuclei(data)
+	}
+	return Scan{}, errors.New("unsupported scan format: " + format)
+}
+
+// portScanBuilder collects port-only results keyed by IP, keeping the order
+// hosts were first seen in
+type portScanBuilder struct {
+	hosts map[string]*ScanHost
+	order []string
+	seen  map[string]bool
+}
+
+func newPortScanBuilder() *portScanBuilder {
+	return &portScanBuilder{
+		hosts: make(map[string]*ScanHost),
+		seen:  make(map[string]bool),
+	}
+}
+
+func (b *portScanBuilder) host(ip string) *ScanHost {
+	h, ok := b.hosts[ip]
+	if !ok {
+		h = &ScanHost{IP: ip, Status: "up", Ports: []ScanPort{}}
+		b.hosts[ip] = h
+		b.order = append(b.order, ip)
+	}
+	return h
+}
+
+func (b *portScanBuilder) addPort(ip string, number uint16, protocol string, service string) {
+	h := b.host(ip)
+	key := ip + "/" + strconv.Itoa(int(number)) + "/" + protocol
+	if b.seen[key] {
+		// Banner records repeat the port; only use them to fill in the service
+		if service != "" {
+			for i := range h.Ports {
+				if h.Ports[i].Number == number && h.Ports[i].Protocol == protocol && h.Ports[i].Service == "" {
+					h.Ports[i].Service = service
+				}
+			}
+		}
+		return
+	}
+	b.seen[key] = true
+	h.Ports = append(h.Ports, ScanPort{
+		Number:   number,
+		State:    "open",
+		Protocol: protocol,
+		Service:  service,
+	})
+}
+
+func (b *portScanBuilder) scan() Scan {
+	scan := Scan{Status: "success", PortsOnly: true, Hosts: []ScanHost{}}
+	for _, ip := range b.order {
+		scan.Hosts = append(scan.Hosts, *b.hosts[ip])
+	}
+	return scan
+}
+
+type masscanRecord struct {
+	IP        string `json:"ip"`
+	Timestamp string `json:"timestamp"`
+	Ports     []struct {
+		Port    uint16 `json:"port"`
+		Proto   string `json:"proto"`
+		Status  string `json:"status"`
+		Service struct {
+			Name string `json:"name"`
+		} `json:"service"`
+	} `json:"ports"`
+}
+
+var trailingCommaPattern = regexp.MustCompile(`,\s*\]\s*$`)
+
+// ParseMasscanJSON parses masscan -oJ output. Older masscan versions leave a
+// trailing comma before the closing bracket, which is tolerated.
+func ParseMasscanJSON(data []byte) (Scan, error) {
+	data = bytes.TrimSpace(data)
+	b := newPortScanBuilder()
+	if len(data) == 0 {
+		return b.scan(), nil
+	}
+	data = trailingCommaPattern.ReplaceAll(data, []byte("]"))
+
+	var records []masscanRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return Scan{}, errors.New("invalid masscan JSON: " + err.Error())
+	}
+
+	var start, end time.Time
+	for _, r := range records {
+		if ts, err := strconv.ParseInt(r.Timestamp, 10, 64); err == nil {
+			t := time.Unix(ts, 0)
+			if start.IsZero() || t.Before(start) {
+				start = t
+			}
+			if t.After(end) {
+				end = t
+			}
+		}
+		for _, p := range r.Ports {
+			if p.Status != "" && p.Status != "open" {
+				continue
+			}
+			b.addPort(r.IP, p.Port, p.Proto, p.Service.Name)
+		}
+	}
+
+	scan := b.scan()
+	scan.StartTime = start
+	scan.EndTime = end
+	return scan, nil
+}
+
+// ParseMasscanList parses masscan -oL output, e.g.
+// "open tcp 80 10.0.0.1 1700000000" and "banner tcp 80 10.0.0.1 1700000000 http ..."
+func ParseMasscanList(data []byte) (Scan, error) {
+	b := newPortScanBuilder()
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			return Scan{}, errors.New("invalid masscan list line " + strconv.Itoa(lineNum))
+		}
+		number, err := strconv.ParseUint(fields[2], 10, 16)
+		if err != nil {
+			return Scan{}, errors.New("invalid port on masscan list line " + strconv.Itoa(lineNum))
+		}
+
+		switch fields[0] {
+		case "open":
+			b.addPort(fields[3], uint16(number), fields[1], "")
+		case "banner":
+			service := ""
+			if len(fields) >= 6 {
+				service = fields[5]
+			}
+			b.addPort(fields[3], uint16(number), fields[1], service)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Scan{}, errors.New("unable to read masscan list: " + err.Error())
+	}
+
+	return b.scan(), nil
+}
+
+type naabuRecord struct {
+	Host     string          `json:"host"`
+	IP       string          `json:"ip"`
+	Port     json.RawMessage `json:"port"`
+	Protocol string          `json:"protocol"`
+}
+
+// ParseNaabu parses naabu JSON lines output. Both the flat
+// {"ip":..,"port":80,"protocol":"tcp"} form and the older nested
+// {"port":{"Port":80}} form are accepted.
+func ParseNaabu(data []byte) (Scan, error) {
+	b := newPortScanBuilder()
+	hostnames := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var r naabuRecord
+		if err := json.Unmarshal(line, &r); err != nil {
+			return Scan{}, errors.New("invalid naabu JSON on line " + strconv.Itoa(lineNum) + ": " + err.Error())
+		}
+
+		var number uint16
+		if err := json.Unmarshal(r.Port, &number); err != nil {
+			var nested struct {
+				Port uint16 `json:"Port"`
+			}
+			if err := json.Unmarshal(r.Port, &nested); err != nil {
+				return Scan{}, errors.New("invalid port on naabu line " + strconv.Itoa(lineNum))
+			}
+			number = nested.Port
+		}
+
+		ip := r.IP
+		if ip == "" {
+			ip = r.Host
+		}
+		if ip == "" || number == 0 {
+			continue
+		}
+		if r.Host != "" && r.Host != ip {
+			hostnames[ip] = r.Host
+		}
+
+		protocol := strings.ToLower(r.Protocol)
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		b.addPort(ip, number, protocol, "")
+	}
+	if err := scanner.Err(); err != nil {
+		return Scan{}, errors.New("unable to read naabu output: " + err.Error())
+	}
+
+	scan := b.scan()
+	for i := range scan.Hosts {
+		scan.Hosts[i].Hostname = hostnames[scan.Hosts[i].IP]
+	}
+	return scan, nil
+}
+
+// ParseRustscan parses rustscan greppable output (-g), e.g. "10.0.0.1 -> [22,80]"
+func ParseRustscan(data []byte) (Scan, error) {
+	b := newPortScanBuilder()
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		ip, ports, found := strings.Cut(line, "->")
+		if !found {
+			return Scan{}, errors.New("invalid rustscan line " + strconv.Itoa(lineNum))
+		}
+		ip = strings.TrimSpace(ip)
+		ports = strings.Trim(strings.TrimSpace(ports), "[]")
+
+		b.host(ip)
+		for _, p := range strings.Split(ports, ",") {
+			p = strings.TrimSpace(p)
+			if p == "" {
+				continue
+			}
+			number, err := strconv.ParseUint(p, 10, 16)
+			if err != nil {
+				return Scan{}, errors.New("invalid port on rustscan line " + strconv.Itoa(lineNum))
+			}
+			b.addPort(ip, uint16(number), "tcp", "")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Scan{}, errors.New("unable to read rustscan output: " + err.Error())
+	}
+
+	return b.scan(), nil
+}
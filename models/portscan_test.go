@@ -0,0 +1,173 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPortScanParsers(t *testing.T) {
+	tests := []struct {
+		name    string
+		parse   func([]byte) (Scan, error)
+		data    string
+		want    []ScanHost
+		wantErr string
+	}{
+		{
+			name:  "masscan json",
+			parse: ParseMasscanJSON,
+			data: `[
+{   "ip": "10.0.1.5",   "timestamp": "1717000010", "ports": [ {"port": 22, "proto": "tcp", "status": "open", "reason": "syn-ack", "ttl": 64} ] }
+,
+{   "ip": "10.0.1.5",   "timestamp": "1717000012", "ports": [ {"port": 22, "proto": "tcp", "service": {"name": "ssh", "banner": "SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6"} } ] }
+,
+{   "ip": "10.0.1.9",   "timestamp": "1717000011", "ports": [ {"port": 161, "proto": "udp", "status": "open", "reason": "response", "ttl": 63} ] }
+,
+{   "ip": "10.0.1.9",   "timestamp": "1717000011", "ports": [ {"port": 443, "proto": "tcp", "status": "closed", "reason": "rst", "ttl": 63} ] }
+]
+`,
+			want: []ScanHost{
+				{IP: "10.0.1.5", Status: "up", Ports: []ScanPort{{Number: 22, State: "open", Protocol: "tcp", Service: "ssh"}}},
+				{IP: "10.0.1.9", Status: "up", Ports: []ScanPort{{Number: 161, State: "open", Protocol: "udp"}}},
+			},
+		},
+		{
+			name:  "masscan json trailing comma",
+			parse: ParseMasscanJSON,
+			data: `[
+{   "ip": "10.0.1.5",   "timestamp": "1717000010", "ports": [ {"port": 80, "proto": "tcp", "status": "open", "reason": "syn-ack", "ttl": 64} ] },
+]`,
+			want: []ScanHost{
+				{IP: "10.0.1.5", Status: "up", Ports: []ScanPort{{Number: 80, State: "open", Protocol: "tcp"}}},
+			},
+		},
+		{
+			name:  "masscan json empty",
+			parse: ParseMasscanJSON,
+			data:  "\n",
+			want:  []ScanHost{},
+		},
+		{
+			name:    "masscan json invalid",
+			parse:   ParseMasscanJSON,
+			data:    `[{"ip": "10.0.1.5", "ports": [`,
+			wantErr: "invalid masscan JSON",
+		},
+		{
+			name:  "masscan list",
+			parse: ParseMasscanList,
+			data: `#masscan
+open tcp 80 10.0.1.5 1717000010
+open tcp 22 10.0.1.5 1717000010
+banner tcp 80 10.0.1.5 1717000012 http HTTP/1.1 200 OK\x0d\x0aServer: nginx
+open udp 53 10.0.1.6 1717000011
+# end
+`,
+			want: []ScanHost{
+				{IP: "10.0.1.5", Status: "up", Ports: []ScanPort{
+					{Number: 80, State: "open", Protocol: "tcp", Service: "http"},
+					{Number: 22, State: "open", Protocol: "tcp"},
+				}},
+				{IP: "10.0.1.6", Status: "up", Ports: []ScanPort{{Number: 53, State: "open", Protocol: "udp"}}},
+			},
+		},
+		{
+			name:    "masscan list bad port",
+			parse:   ParseMasscanList,
+			data:    "#masscan\nopen tcp http 10.0.1.5 1717000010\n",
+			wantErr: "invalid port on masscan list line 2",
+		},
+		{
+			name:  "naabu",
+			parse: ParseNaabu,
+			data: `{"host":"web01.team1.lan","ip":"10.0.1.5","timestamp":"2024-05-29T16:26:40.123Z","port":443,"protocol":"tcp","tls":true}
+{"host":"10.0.1.6","ip":"10.0.1.6","timestamp":"2024-05-29T16:26:41.456Z","port":22,"protocol":"tcp","tls":false}
+
+{"host":"10.0.1.6","ip":"10.0.1.6","port":{"Port":8080,"Protocol":0,"TLS":false},"timestamp":"2024-05-29T16:26:41Z"}
+{"host":"web01.team1.lan","ip":"10.0.1.5","timestamp":"2024-05-29T16:26:42Z","port":443,"protocol":"tcp","tls":true}
+`,
+			want: []ScanHost{
+				{IP: "10.0.1.5", Hostname: "web01.team1.lan", Status: "up", Ports: []ScanPort{{Number: 443, State: "open", Protocol: "tcp"}}},
+				{IP: "10.0.1.6", Status: "up", Ports: []ScanPort{
+					{Number: 22, State: "open", Protocol: "tcp"},
+					{Number: 8080, State: "open", Protocol: "tcp"},
+				}},
+			},
+		},
+		{
+			name:    "naabu text output",
+			parse:   ParseNaabu,
+			data:    "10.0.1.5:443\n",
+			wantErr: "invalid naabu JSON on line 1",
+		},
+		{
+			name:  "rustscan",
+			parse: ParseRustscan,
+			data: `10.0.1.5 -> [22,80,443]
+10.0.1.6 -> []
+10.0.1.7 -> [3389]
+`,
+			want: []ScanHost{
+				{IP: "10.0.1.5", Status: "up", Ports: []ScanPort{
+					{Number: 22, State: "open", Protocol: "tcp"},
+					{Number: 80, State: "open", Protocol: "tcp"},
+					{Number: 443, State: "open", Protocol: "tcp"},
+				}},
+				{IP: "10.0.1.6", Status: "up", Ports: []ScanPort{}},
+				{IP: "10.0.1.7", Status: "up", Ports: []ScanPort{{Number: 3389, State: "open", Protocol: "tcp"}}},
+			},
+		},
+		{
+			name:    "rustscan normal output",
+			parse:   ParseRustscan,
+			data:    "Open 10.0.1.5:22\n",
+			wantErr: "invalid rustscan line 1",
+		},
+		{
+			name:    "rustscan bad port",
+			parse:   ParseRustscan,
+			data:    "10.0.1.5 -> [22,ssh]\n",
+			wantErr: "invalid port on rustscan line 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scan, err := tt.parse([]byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !scan.PortsOnly {
+				t.Error("port sweeper results should be ports only")
+			}
+			if !reflect.DeepEqual(scan.Hosts, tt.want) {
+				t.Errorf("hosts = %+v\nwant %+v", scan.Hosts, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseScanFormat(t *testing.T) {
+	scan, err := ParseScan(ScanFormatRustscan, []byte("10.0.1.5 -> [22]\n"))
+	if err != nil || len(scan.Hosts) != 1 {
+		t.Fatalf("rustscan: hosts = %+v, err = %v", scan.Hosts, err)
+	}
+	// Agent JSON is a full scan, whatever the upload claims
+	scan, err = ParseScan(ScanFormatJSON, []byte(`{"status":"success","ports_only":true,"hosts":[{"ip":"10.0.1.5"}]}`))
+	if err != nil || len(scan.Hosts) != 1 {
+		t.Fatalf("json: hosts = %+v, err = %v", scan.Hosts, err)
+	}
+	if scan.PortsOnly {
+		t.Error("json: ports_only was taken from the upload")
+	}
+	if _, err := ParseScan("nikto", []byte("{}")); err == nil || !strings.Contains(err.Error(), "unsupported scan format") {
+		t.Errorf("unknown format: err = %v", err)
+	}
+}
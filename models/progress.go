@@ -0,0 +1,149 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// jobLogLimit is how many log lines are kept per job
+const jobLogLimit = 500
+
+// maxLogLine truncates overly long log lines
+const maxLogLine = 1000
+
+// JobProgressRequest is sent by a scanner while a job runs. Hosts holds the
+// results of hosts the scanner has finished, which are merged right away.
+type JobProgressRequest struct {
+	Percent    float64    `json:"percent"`
+	HostsDone  int        `json:"hosts_done"`
+	HostsTotal int        `json:"hosts_total"`
+	ETASeconds int        `json:"eta_seconds"` // 0 if unknown
+	Log        []string   `json:"log"`
+	Hosts      []ScanHost `json:"hosts"`
+}
+
+// Validate checks the reported numbers
+func (r JobProgressRequest) Validate() error {
+	if r.Percent < 0 || r.Percent > 100 {
+		return errors.New("percent must be between 0 and 100")
+	}
+	if r.HostsDone < 0 || r.HostsTotal < 0 || r.ETASeconds < 0 {
+		return errors.New("hosts_done, hosts_total and eta_seconds may not be negative")
+	}
+	return nil
+}
+
+// JobLog is a line of scanner output reported with progress
+type JobLog struct {
+	gorm.Model `json:"-"`
+	JID        string    `json:"-" gorm:"column:j_id;index"`
+	Time       time.Time `json:"time"`
+	Line       string    `json:"line"`
+}
+
+// JobProgress is the live state of a job for the jobs page
+type JobProgress struct {
+	JID        string     `json:"jid"`
+	Status     string     `json:"status"`
+	Percent    float64    `json:"percent"`
+	HostsDone  int        `json:"hosts_done"`
+	HostsTotal int        `json:"hosts_total"`
+	ETA        *time.Time `json:"eta,omitempty"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"` // last progress report
+	Log        []JobLog   `json:"log"`
+}
+
+// RecordProgress stores a progress report on a running job and appends its
+// log lines, dropping the oldest past jobLogLimit
+func RecordProgress(tx *gorm.DB, job *Job, req JobProgressRequest) error {
+	now := time.Now()
+	job.Percent = req.Percent
+	job.HostsDone = req.HostsDone
+	job.HostsTotal = req.HostsTotal
+	job.ETA = time.Time{}
+	if req.ETASeconds > 0 {
+		job.ETA = now.Add(time.Duration(req.ETASeconds) * time.Second)
+	}
+	job.ProgressAt = now
+	job.ExtendLease()
+
+	// The job may have been reaped or cancelled since it was loaded
+	result := tx.Model(&Job{}).Where("id = ? AND status = ?", job.ID, "running").Updates(map[string]interface{}{
+		"percent":          job.Percent,
+		"hosts_done":       job.HostsDone,
+		"hosts_total":      job.HostsTotal,
+		"eta":              job.ETA,
+		"progress_at":      job.ProgressAt,
+		"lease_expires_at": job.LeaseExpiresAt,
+		"last_heartbeat":   job.LastHeartbeat,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrJobNotRunning
+	}
+
+	if len(req.Log) == 0 {
+		return nil
+	}
+	lines := req.Log
+	if len(lines) > jobLogLimit {
+		lines = lines[len(lines)-jobLogLimit:]
+	}
+	logs := make([]JobLog, 0, len(lines))
+	for _, line := range lines {
+		if len(line) > maxLogLine {
+			line = line[:maxLogLine]
+		}
+		logs = append(logs, JobLog{JID: job.JID, Time: now, Line: line})
+	}
+	if err := tx.CreateInBatches(&logs, 100).Error; err != nil {
+		return err
+	}
+
+	var oldest JobLog
+	err := tx.Where("j_id = ?", job.JID).Order("id DESC").Offset(jobLogLimit).First(&oldest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Unscoped().Where("j_id = ? AND id <= ?", job.JID, oldest.ID).Delete(&JobLog{}).Error
+}
+
+// LoadJobProgress returns the progress of each job with its latest log
+// lines, oldest first
+func LoadJobProgress(jobs []Job, lines int) ([]JobProgress, error) {
+	db := GetDB()
+	progress := make([]JobProgress, 0, len(jobs))
+	for _, job := range jobs {
+		p := JobProgress{
+			JID:        job.JID,
+			Status:     job.Status,
+			Percent:    job.Percent,
+			HostsDone:  job.HostsDone,
+			HostsTotal: job.HostsTotal,
+			Log:        []JobLog{},
+		}
+		if !job.ETA.IsZero() {
+			p.ETA = &job.ETA
+		}
+		if !job.ProgressAt.IsZero() {
+			p.UpdatedAt = &job.ProgressAt
+		}
+		if lines > 0 {
+			if err := db.Where("j_id = ?", job.JID).Order("id DESC").Limit(lines).Find(&p.Log).Error; err != nil {
+				return nil, err
+			}
+			for i, j := 0, len(p.Log)-1; i < j; i, j = i+1, j-1 {
+				p.Log[i], p.Log[j] = p.Log[j], p.Log[i]
+			}
+		}
+		progress = append(progress, p)
+	}
+	return progress, nil
+}
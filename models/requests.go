@@ -14,8 +14,20 @@ type RegisterReq struct {
 
 // Scan request structs - separate from GORM models for proper JSON binding
 type ScanScriptResult struct {
-	Name   string `json:"name"`
-	Output string `json:"output"`
+	Name     string `json:"name"`
+	Output   string `json:"output"`
+	Severity string `json:"severity,omitempty"` // set by scanners that rate their findings
+	Source   string `json:"source,omitempty"`
+}
+
+type ScanFinding struct {
+	Source      string   `json:"source"`
+	TemplateID  string   `json:"template_id"`
+	Name        string   `json:"name"`
+	Severity    string   `json:"severity"`
+	MatchedAt   string   `json:"matched_at"`
+	Extracted   []string `json:"extracted,omitempty"`
+	Description string   `json:"description"`
 }
 
 type ScanPort struct {
@@ -25,6 +37,7 @@ type ScanPort struct {
 	Service  string             `json:"service"`
 	Version  string             `json:"version"`
 	Scripts  []ScanScriptResult `json:"scripts,omitempty"`
+	Findings []ScanFinding      `json:"findings,omitempty"`
 }
 
 type ScanHost struct {
@@ -33,6 +46,8 @@ type ScanHost struct {
 	OS       string     `json:"os"`
 	Status   string     `json:"status"`
 	Ports    []ScanPort `json:"ports"`
+	// Scripts are results about the host rather than one port
+	Scripts []ScanScriptResult `json:"scripts,omitempty"`
 }
 
 type Scan struct {
@@ -40,4 +55,9 @@ type Scan struct {
 	StartTime time.Time  `json:"start_time"`
 	EndTime   time.Time  `json:"end_time"`
 	Hosts     []ScanHost `json:"hosts"`
+	// PortsOnly marks results that only add to what is already known (port
+	// sweepers, vulnerability reports) and must not overwrite service/version
+	// data from earlier scans. Only the parsers set it; it is not taken from
+	// uploaded JSON, which is a full scan
+	PortsOnly bool `json:"-"`
 }
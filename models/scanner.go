@@ -0,0 +1,175 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultScannerOfflineAfter is how long a scanner may go unheard before it
+// is shown as offline
+const DefaultScannerOfflineAfter = 2 * time.Minute
+
+// ErrScannerOwner is returned when an account uses a scanner name registered
+// by another account
+var ErrScannerOwner = errors.New("scanner name is registered to another account")
+
+// Scanner is a registered scanner agent. Agents identify themselves by name;
+// several agents may share one scanner account.
+type Scanner struct {
+	gorm.Model    `json:"-"`
+	Name          string     `json:"name" gorm:"uniqueIndex"`
+	User          string     `json:"user"` // account the agent authenticates as
+	Version       string     `json:"version"`
+	IP            string     `json:"ip"`
+	JobTypes      []string   `json:"job_types" gorm:"serializer:json"` // empty accepts every job type
+	Tags          []string   `json:"tags" gorm:"serializer:json"`      // network pools the agent sits in, set by admins
+	LastHeartbeat time.Time  `json:"last_heartbeat"`
+	Online        bool       `json:"online" gorm:"-"`
+	CurrentJob    *Job       `json:"current_job,omitempty" gorm:"-"`
+	Throughput    Throughput `json:"throughput" gorm:"-"`
+}
+
+// ScannerRegistration is sent by an agent when it starts
+type ScannerRegistration struct {
+	Name     string   `json:"name"` // defaults to the X-Scanner-Name header, then the account name
+	Version  string   `json:"version"`
+	JobTypes []string `json:"job_types"`
+}
+
+// ScannerTagsRequest sets the tags of a scanner
+type ScannerTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+var scannerTagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,31}$`)
+
+// ValidateScannerTags checks a list of scanner tags
+func ValidateScannerTags(tags []string) error {
+	for _, tag := range tags {
+		if !scannerTagPattern.MatchString(tag) {
+			return errors.New("invalid scanner tag " + strconv.Quote(tag) + ": use lowercase letters, digits, '-', '_' or '.'")
+		}
+	}
+	return nil
+}
+
+// Throughput summarises the jobs a scanner finished over a period
+type Throughput struct {
+	Completed  int     `json:"completed"`
+	Failed     int     `json:"failed"`
+	HostsFound int     `json:"hosts_found"`
+	AvgSeconds float64 `json:"avg_seconds"` // mean run time of completed jobs
+}
+
+// ScannerOfflineAfter returns the offline threshold from SCANNER_OFFLINE_SECONDS
+func ScannerOfflineAfter() time.Duration {
+	if v := os.Getenv("SCANNER_OFFLINE_SECONDS"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+			return time.Duration(secs) * time.Second
+		}
+		fmt.Printf("Warning: invalid SCANNER_OFFLINE_SECONDS %q, using %s\n", v, DefaultScannerOfflineAfter)
+	}
+	return DefaultScannerOfflineAfter
+}
+
+// Supports reports whether the scanner accepts a job type
+func (s *Scanner) Supports(jobType string) bool {
+	return len(s.JobTypes) == 0 || slices.Contains(s.JobTypes, jobType)
+}
+
+// CanReach reports whether the scanner may be given work for a team. Teams
+// without routing tags can be scanned from anywhere.
+func (s *Scanner) CanReach(team Team) bool {
+	if len(team.ScannerTags) == 0 {
+		return true
+	}
+	for _, tag := range team.ScannerTags {
+		if slices.Contains(s.Tags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// TouchScanner records that a scanner was heard from at ip, registering it
+// on first contact
+func TouchScanner(name string, user string, ip string) (Scanner, error) {
+	db := GetDB()
+	var scanner Scanner
+	err := db.First(&scanner, "name = ?", name).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return scanner, err
+	}
+	if err == nil && scanner.User != user {
+		return scanner, ErrScannerOwner
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		scanner = Scanner{Name: name, User: user, IP: ip, LastHeartbeat: time.Now()}
+		return scanner, db.Create(&scanner).Error
+	}
+
+	// Only the contact columns are written, so a registration or an admin
+	// editing tags at the same time is not undone
+	scanner.User = user
+	scanner.IP = ip
+	scanner.LastHeartbeat = time.Now()
+	err = db.Model(&scanner).Updates(map[string]interface{}{
+		"ip":             scanner.IP,
+		"user":           scanner.User,
+		"last_heartbeat": scanner.LastHeartbeat,
+	}).Error
+	return scanner, err
+}
+
+// LoadScannerState fills in each scanner's online flag, the job it is
+// running and its throughput since a point in time
+func LoadScannerState(scanners []Scanner, since time.Time) error {
+	db := GetDB()
+
+	var rows []struct {
+		Scanner    string
+		Completed  int
+		Failed     int
+		HostsFound int
+		AvgSeconds float64
+	}
+	result := db.Model(&Job{}).
+		Select("scanner, SUM(CASE WHEN status = 'complete' THEN 1 ELSE 0 END) AS completed, "+
+			"SUM(CASE WHEN status = 'failed' THEN 1 ELSE 0 END) AS failed, "+
+			"SUM(CASE WHEN status = 'complete' THEN hosts_found ELSE 0 END) AS hosts_found, "+
+			"COALESCE(AVG(CASE WHEN status = 'complete' THEN (julianday(completed_at) - julianday(started_at)) * 86400 END), 0) AS avg_seconds").
+		Where("status IN ? AND completed_at >= ?", []string{"complete", "failed"}, since).
+		Group("scanner").Scan(&rows)
+	if result.Error != nil {
+		return result.Error
+	}
+	throughput := make(map[string]Throughput)
+	for _, row := range rows {
+		throughput[row.Scanner] = Throughput{Completed: row.Completed, Failed: row.Failed, HostsFound: row.HostsFound, AvgSeconds: row.AvgSeconds}
+	}
+
+	var running []Job
+	if err := db.Where("status = ?", "running").Order("started_at ASC").Find(&running).Error; err != nil {
+		return err
+	}
+	current := make(map[string]*Job)
+	for i := range running {
+		current[running[i].Scanner] = &running[i]
+	}
+
+	cutoff := time.Now().Add(-ScannerOfflineAfter())
+	for i := range scanners {
+		scanners[i].Online = scanners[i].LastHeartbeat.After(cutoff)
+		scanners[i].CurrentJob = current[scanners[i].Name]
+		scanners[i].Throughput = throughput[scanners[i].Name]
+	}
+	return nil
+}
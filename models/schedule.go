@@ -0,0 +1,190 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Schedule controls how often a job type scans a team. A schedule without
+// a team is the default for every team of that job type; a team-specific
+// schedule takes precedence. Teams with no schedule are always due.
+type Schedule struct {
+	gorm.Model      `json:"-"`
+	SID             string     `json:"sid" gorm:"uniqueIndex;column:s_id"`
+	TeamID          string     `json:"team_id" gorm:"index"` // empty for the job type default
+	JobType         string     `json:"job_type" gorm:"index"`
+	Cron            string     `json:"cron"`             // e.g. "*/10 * * * *"
+	IntervalSeconds int        `json:"interval_seconds"` // minimum time between scans
+	NextDue         *time.Time `json:"next_due,omitempty" gorm:"-"`
+}
+
+// ScheduleRequest for creating/updating schedules via API
+type ScheduleRequest struct {
+	TeamID          string `json:"team_id"`
+	JobType         string `json:"job_type" binding:"required"`
+	Cron            string `json:"cron"`
+	IntervalSeconds int    `json:"interval_seconds"`
+}
+
+func MakeSchedule(teamID string, jobType string) Schedule {
+	var s Schedule
+	s.SID = uuid.New().String()
+	s.TeamID = teamID
+	s.JobType = jobType
+	return s
+}
+
+// Validate checks that exactly one of cron and interval is set
+func (s Schedule) Validate() error {
+	if (s.Cron == "") == (s.IntervalSeconds == 0) {
+		return errors.New("set either cron or interval_seconds")
+	}
+	if s.IntervalSeconds < 0 {
+		return errors.New("interval_seconds must be positive")
+	}
+	if s.Cron != "" {
+		c, err := ParseCron(s.Cron)
+		if err != nil {
+			return err
+		}
+		if c.Next(time.Now()).IsZero() {
+			return errors.New("cron expression never fires")
+		}
+	}
+	return nil
+}
+
+// DueAt returns when a team on this schedule should next be scanned, given
+// when its last scan started. A team never scanned is due immediately.
+func (s Schedule) DueAt(lastRun time.Time) time.Time {
+	if lastRun.IsZero() {
+		return time.Time{}
+	}
+	if s.IntervalSeconds > 0 {
+		return lastRun.Add(time.Duration(s.IntervalSeconds) * time.Second)
+	}
+	c, err := ParseCron(s.Cron)
+	if err != nil {
+		return time.Time{}
+	}
+	return c.Next(lastRun)
+}
+
+// NoWorkError is returned by GetNextJob when no team is due yet
+type NoWorkError struct {
+	RetryAfter time.Duration
+	Reason     string // defaults to "no work due"
+}
+
+func (e *NoWorkError) Error() string {
+	reason := e.Reason
+	if reason == "" {
+		reason = "no work due"
+	}
+	return fmt.Sprintf("%s, retry after %d seconds", reason, e.RetrySeconds())
+}
+
+// RetrySeconds rounds RetryAfter up to whole seconds
+func (e *NoWorkError) RetrySeconds() int {
+	secs := int((e.RetryAfter + time.Second - 1) / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	return secs
+}
+
+// teamDueTimes returns when each team is next due for a job type, given
+// when each was last scanned. Teams missing from the map have no schedule
+// and are always due.
+func teamDueTimes(jobType string, teams []Team, lastRuns map[string]time.Time) (map[string]time.Time, error) {
+	db := GetDB()
+	var schedules []Schedule
+	if err := db.Where("job_type = ?", jobType).Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	if len(schedules) == 0 {
+		return map[string]time.Time{}, nil
+	}
+
+	var fallback *Schedule
+	byTeam := make(map[string]Schedule)
+	for i := range schedules {
+		if schedules[i].TeamID == "" {
+			fallback = &schedules[i]
+		} else {
+			byTeam[schedules[i].TeamID] = schedules[i]
+		}
+	}
+
+	due := make(map[string]time.Time)
+	for _, team := range teams {
+		s, ok := byTeam[team.TID]
+		if !ok {
+			if fallback == nil {
+				continue
+			}
+			s = *fallback
+		}
+		due[team.TID] = s.DueAt(lastRuns[team.TID])
+	}
+	return due, nil
+}
+
+// lastScanStarts returns when each team's most recent scan of a job type
+// was handed out. Retries belong to the scan they retry and are ignored, as
+// are ad hoc scans, which do not move a team's schedule.
+func lastScanStarts(jobType string) (map[string]time.Time, error) {
+	var rows []struct {
+		TID  string
+		Last string
+	}
+	result := GetDB().Model(&Job{}).Select("t_id AS t_id, MAX(created_at) AS last").
+		Where("type = ? AND retries = 0 AND ad_hoc = ?", jobType, false).Group("t_id").Scan(&rows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	last := make(map[string]time.Time)
+	for _, row := range rows {
+		if t, err := parseDBTime(row.Last); err == nil {
+			last[row.TID] = t
+		}
+	}
+	return last, nil
+}
+
+// parseDBTime parses a timestamp returned by an SQLite aggregate, which
+// comes back as text rather than a time
+func parseDBTime(s string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02 15:04:05.999999999-07:00", time.RFC3339Nano} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("unrecognised time: " + s)
+}
+
+// ScheduleNextDue fills in NextDue for team-specific schedules
+func ScheduleNextDue(schedules []Schedule) error {
+	cache := make(map[string]map[string]time.Time)
+	for i := range schedules {
+		if schedules[i].TeamID == "" {
+			continue
+		}
+		last, ok := cache[schedules[i].JobType]
+		if !ok {
+			var err error
+			if last, err = lastScanStarts(schedules[i].JobType); err != nil {
+				return err
+			}
+			cache[schedules[i].JobType] = last
+		}
+		due := schedules[i].DueAt(last[schedules[i].TeamID])
+		schedules[i].NextDue = &due
+	}
+	return nil
+}
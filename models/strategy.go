@@ -0,0 +1,184 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Scheduling strategies for choosing the next team
+const (
+	StrategyRoundRobin    = "round-robin"            // teams in name order, in turn
+	StrategyLeastRecent   = "least-recently-scanned" // the team whose last scan is oldest
+	StrategyWeighted      = "weighted"               // time since last scan multiplied by team weight
+	StrategyBoostOnChange = "boost-on-change"        // teams with recent port changes first, then least recent
+)
+
+// DefaultBoostWindow is how recent a port change must be to boost a team
+const DefaultBoostWindow = 15 * time.Minute
+
+// ValidateStrategy checks a strategy name; empty means round-robin
+func ValidateStrategy(strategy string) error {
+	switch strategy {
+	case "", StrategyRoundRobin, StrategyLeastRecent, StrategyWeighted, StrategyBoostOnChange:
+		return nil
+	}
+	return errors.New("strategy must be one of round-robin, least-recently-scanned, weighted, boost-on-change")
+}
+
+// BoostWindow returns the boost-on-change window from SCHEDULER_BOOST_MINUTES
+func BoostWindow() time.Duration {
+	if v := os.Getenv("SCHEDULER_BOOST_MINUTES"); v != "" {
+		if mins, err := strconv.Atoi(v); err == nil && mins > 0 {
+			return time.Duration(mins) * time.Minute
+		}
+		fmt.Printf("Warning: invalid SCHEDULER_BOOST_MINUTES %q, using %s\n", v, DefaultBoostWindow)
+	}
+	return DefaultBoostWindow
+}
+
+// QueueEntry is a team's place in a job type's queue
+type QueueEntry struct {
+	Position     int        `json:"position"`
+	TeamID       string     `json:"team_id"`
+	TeamName     string     `json:"team_name"`
+	Weight       int        `json:"weight"`
+	LastScan     *time.Time `json:"last_scan,omitempty"`
+	DueAt        *time.Time `json:"due_at,omitempty"` // unset when due now
+	Boosted      bool       `json:"boosted,omitempty"`
+	Unroutable   bool       `json:"unroutable,omitempty"`    // no registered scanner for the job type can reach the team
+	Blocked      bool       `json:"blocked,omitempty"`       // inside a blackout or outside every allow window
+	BlockedUntil *time.Time `json:"blocked_until,omitempty"` // when the block lifts, if known
+	Changes      int        `json:"recent_changes,omitempty"`
+	team         Team
+}
+
+// TeamQueue orders the teams for this job type under its strategy. Teams
+// that are not yet due keep their place but are skipped by GetNextJob.
+func (js *JobStatus) TeamQueue() ([]QueueEntry, error) {
+	db := GetDB()
+	var teams []Team
+	if err := db.Order("name ASC").Find(&teams).Error; err != nil {
+		return nil, errors.New("database error loading teams")
+	}
+	return js.teamQueue(teams, time.Now())
+}
+
+func (js *JobStatus) teamQueue(teams []Team, now time.Time) ([]QueueEntry, error) {
+	lastRuns, err := lastScanStarts(js.Name)
+	if err != nil {
+		return nil, errors.New("database error loading scan history")
+	}
+	due, err := teamDueTimes(js.Name, teams, lastRuns)
+	if err != nil {
+		return nil, errors.New("database error loading schedules")
+	}
+
+	blocks, err := WindowBlocks(GetDB(), teams, now)
+	if err != nil {
+		return nil, errors.New("database error loading scan windows")
+	}
+
+	var scanners []Scanner
+	if err := GetDB().Find(&scanners).Error; err != nil {
+		return nil, errors.New("database error loading scanners")
+	}
+
+	var changes map[string]int
+	if js.Strategy == StrategyBoostOnChange {
+		if changes, err = recentPortChanges(now.Add(-BoostWindow()), lastRuns); err != nil {
+			return nil, errors.New("database error loading port events")
+		}
+	}
+
+	queue := make([]QueueEntry, len(teams))
+	for i, team := range teams {
+		entry := QueueEntry{TeamID: team.TID, TeamName: team.Name, Weight: team.Weight, team: team}
+		if entry.Weight < 1 {
+			entry.Weight = 1
+		}
+		if last, ok := lastRuns[team.TID]; ok {
+			entry.LastScan = &last
+		}
+		if dueAt := due[team.TID]; dueAt.After(now) {
+			entry.DueAt = &dueAt
+		}
+		entry.Changes = changes[team.TID]
+		entry.Boosted = entry.Changes > 0
+		entry.Unroutable = !js.routable(team, scanners)
+		if until, blocked := blocks[team.TID]; blocked {
+			entry.Blocked = true
+			if !until.IsZero() {
+				entry.BlockedUntil = &until
+			}
+		}
+		queue[i] = entry
+	}
+
+	// Never-scanned teams count as scanned at the epoch, so they come first
+	age := func(e QueueEntry) time.Duration {
+		if e.LastScan == nil {
+			return now.Sub(time.Unix(0, 0))
+		}
+		return now.Sub(*e.LastScan)
+	}
+
+	switch js.Strategy {
+	case StrategyLeastRecent:
+		sort.SliceStable(queue, func(a, b int) bool { return age(queue[a]) > age(queue[b]) })
+	case StrategyWeighted:
+		sort.SliceStable(queue, func(a, b int) bool {
+			return age(queue[a]).Seconds()*float64(queue[a].Weight) > age(queue[b]).Seconds()*float64(queue[b].Weight)
+		})
+	case StrategyBoostOnChange:
+		sort.SliceStable(queue, func(a, b int) bool {
+			if queue[a].Boosted != queue[b].Boosted {
+				return queue[a].Boosted
+			}
+			return age(queue[a]) > age(queue[b])
+		})
+	default:
+		// Round-robin: rotate the name order to start at the saved index
+		if len(queue) > 0 {
+			start := js.JobIndex % len(queue)
+			queue = append(queue[start:], queue[:start]...)
+		}
+	}
+
+	for i := range queue {
+		queue[i].Position = i + 1
+	}
+	return queue, nil
+}
+
+// routable reports whether any scanner that runs this job type can reach a team
+func (js *JobStatus) routable(team Team, scanners []Scanner) bool {
+	for i := range scanners {
+		if scanners[i].Supports(js.Name) && scanners[i].CanReach(team) {
+			return true
+		}
+	}
+	return false
+}
+
+// recentPortChanges counts port events per team since a point in time.
+// Changes from before a team's last scan were already picked up by it, so
+// they no longer count.
+func recentPortChanges(since time.Time, lastRuns map[string]time.Time) (map[string]int, error) {
+	var events []PortEvent
+	result := GetDB().Select("team_id", "time").Where("time >= ?", since).Find(&events)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	counts := make(map[string]int)
+	for _, event := range events {
+		if last, ok := lastRuns[event.TeamID]; ok && !event.Time.After(last) {
+			continue
+		}
+		counts[event.TeamID]++
+	}
+	return counts, nil
+}
@@ -1,31 +1,30 @@
 package models
 
 import (
-	"errors"
-	"net"
-	"regexp"
-	"strings"
-
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 type Team struct {
 	gorm.Model  `json:"-"`
-	Name        string `json:"name" gorm:"uniqueIndex"`
-	IPRange     string `json:"iprange"`
-	TID         string `json:"tid" gorm:"uniqueIndex;column:t_id"`
-	Description string `json:"description"`
-	Color       string `json:"color"` // Hex color for UI display
-	Hosts       []Host `json:"hosts,omitempty" gorm:"foreignKey:TeamID;references:TID;constraint:OnDelete:CASCADE"`
+	Name        string   `json:"name" gorm:"uniqueIndex"`
+	IPRange     string   `json:"iprange"`
+	TID         string   `json:"tid" gorm:"uniqueIndex;column:t_id"`
+	Description string   `json:"description"`
+	Color       string   `json:"color"`                               // Hex color for UI display
+	Weight      int      `json:"weight" gorm:"default:1"`             // share of scans under the weighted strategy
+	ScannerTags []string `json:"scanner_tags" gorm:"serializer:json"` // only scanners with one of these tags can reach the team; empty = any
+	Hosts       []Host   `json:"hosts,omitempty" gorm:"foreignKey:TeamID;references:TID;constraint:OnDelete:CASCADE"`
 }
 
 // TeamRequest for creating/updating teams via API
 type TeamRequest struct {
-	Name        string `json:"name" binding:"required"`
-	IPRange     string `json:"iprange" binding:"required"`
-	Description string `json:"description"`
-	Color       string `json:"color"`
+	Name        string   `json:"name" binding:"required"`
+	IPRange     string   `json:"iprange" binding:"required"`
+	Description string   `json:"description"`
+	Color       string   `json:"color"`
+	Weight      int      `json:"weight"`
+	ScannerTags []string `json:"scanner_tags"`
 }
 
 func MakeTeam(name string, iprange string) Team {
@@ -34,50 +33,16 @@ func MakeTeam(name string, iprange string) Team {
 	team.IPRange = iprange
 	team.TID = uuid.New().String()
 	team.Color = generateTeamColor(name)
+	team.Weight = 1
 	return team
 }
 
-// ValidateIPRange checks if the IP range is valid for nmap
+// ValidateIPRange checks if the IP range is valid for nmap. It accepts
+// exactly what ParseIPSet does, so a stored range can always be split and
+// scoped later.
 func ValidateIPRange(iprange string) error {
-	iprange = strings.TrimSpace(iprange)
-	
-	if iprange == "" {
-		return errors.New("IP range cannot be empty")
-	}
-
-	// Check for CIDR notation (e.g., 192.168.1.0/24)
-	if strings.Contains(iprange, "/") {
-		_, _, err := net.ParseCIDR(iprange)
-		if err == nil {
-			return nil
-		}
-	}
-
-	// Check for range notation (e.g., 192.168.1.1-254)
-	rangePattern := regexp.MustCompile(`^(\d{1,3}\.){3}\d{1,3}-\d{1,3}$`)
-	if rangePattern.MatchString(iprange) {
-		return nil
-	}
-
-	// Check for single IP
-	if net.ParseIP(iprange) != nil {
-		return nil
-	}
-
-	// Check for comma-separated IPs
-	ips := strings.Split(iprange, ",")
-	for _, ip := range ips {
-		ip = strings.TrimSpace(ip)
-		if net.ParseIP(ip) == nil {
-			// Check if it's a valid CIDR
-			_, _, err := net.ParseCIDR(ip)
-			if err != nil {
-				return errors.New("invalid IP address or range: " + ip)
-			}
-		}
-	}
-
-	return nil
+	_, err := ParseIPSet(iprange)
+	return err
 }
 
 // generateTeamColor creates a consistent color based on team name
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// useTestDB points the package at a fresh, migrated database in a temporary
+// directory for the rest of the test
+func useTestDB(t *testing.T) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	// Store the admin first, or Init spends a second or two hashing its
+	// password at full bcrypt cost
+	pre, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := MakeUser("admin")
+	if err := pre.AutoMigrate(&User{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pre.Create(&admin).Error; err != nil {
+		t.Fatal(err)
+	}
+	if sqlDB, err := pre.DB(); err == nil {
+		sqlDB.Close()
+	}
+
+	t.Setenv("DB_PATH", path)
+	t.Setenv("GIN_MODE", "release")
+	Init()
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+}
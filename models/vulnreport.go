@@ -0,0 +1,323 @@
+package models
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Finding severities, highest first
+const (
+	SeverityCritical = "critical"
+	SeverityHigh     = "high"
+	SeverityMedium   = "medium"
+	SeverityLow      = "low"
+	SeverityInfo     = "info"
+)
+
+// SeverityFromCVSS maps a CVSS base score onto a finding severity
+func SeverityFromCVSS(score float64) string {
+	switch {
+	case score >= 9.0:
+		return SeverityCritical
+	case score >= 7.0:
+		return SeverityHigh
+	case score >= 4.0:
+		return SeverityMedium
+	case score > 0:
+		return SeverityLow
+	}
+	return SeverityInfo
+}
+
+// reportBuilder collects hosts, ports and findings from vulnerability
+// scanner reports. Findings that are not tied to a port (port 0 / "general")
+// are kept on the host, so no port is made up for them.
+type reportBuilder struct {
+	*portScanBuilder
+}
+
+func newReportBuilder() *reportBuilder {
+	return &reportBuilder{portScanBuilder: newPortScanBuilder()}
+}
+
+func (b *reportBuilder) addFinding(ip string, number uint16, protocol string, finding ScanScriptResult) {
+	h := b.host(ip)
+	if number == 0 {
+		h.Scripts = append(h.Scripts, finding)
+		return
+	}
+	for i := range h.Ports {
+		if h.Ports[i].Number == number && h.Ports[i].Protocol == protocol {
+			h.Ports[i].Scripts = append(h.Ports[i].Scripts, finding)
+			return
+		}
+	}
+}
+
+// formatFindingOutput joins the parts of a report item into a single output block
+func formatFindingOutput(synopsis string, output string, cves []string) string {
+	parts := []string{}
+	if s := strings.TrimSpace(synopsis); s != "" {
+		parts = append(parts, s)
+	}
+	if s := strings.TrimSpace(output); s != "" {
+		parts = append(parts, s)
+	}
+	if len(cves) > 0 {
+		parts = append(parts, "CVE: "+strings.Join(cves, ", "))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// .nessus (NessusClientData_v2) document structs
+type nessusReport struct {
+	XMLName xml.Name `xml:"NessusClientData_v2"`
+	Hosts   []struct {
+		Name       string `xml:"name,attr"`
+		Properties []struct {
+			Name  string `xml:"name,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"HostProperties>tag"`
+		Items []struct {
+			Port         uint16   `xml:"port,attr"`
+			SvcName      string   `xml:"svc_name,attr"`
+			Protocol     string   `xml:"protocol,attr"`
+			Severity     int      `xml:"severity,attr"`
+			PluginID     string   `xml:"pluginID,attr"`
+			PluginName   string   `xml:"pluginName,attr"`
+			Synopsis     string   `xml:"synopsis"`
+			Description  string   `xml:"description"`
+			PluginOutput string   `xml:"plugin_output"`
+			CVEs         []string `xml:"cve"`
+		} `xml:"ReportItem"`
+	} `xml:"Report>ReportHost"`
+}
+
+var nessusSeverities = []string{SeverityInfo, SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical}
+
+// ParseNessus parses a Nessus v2 (.nessus) XML report. Every ReportItem on a
+// non-zero port marks that port open; items with a severity above
+// informational are stored as findings with the plugin's severity.
+func ParseNessus(data []byte) (Scan, error) {
+	var report nessusReport
+	if err := xml.Unmarshal(data, &report); err != nil {
+		return Scan{}, errors.New("invalid Nessus report: " + err.Error())
+	}
+
+	b := newReportBuilder()
+	hostnames := make(map[string]string)
+	oses := make(map[string]string)
+
+	for _, rh := range report.Hosts {
+		ip := rh.Name
+		for _, prop := range rh.Properties {
+			if prop.Name == "host-ip" {
+				ip = strings.TrimSpace(prop.Value)
+			}
+		}
+		for _, prop := range rh.Properties {
+			switch prop.Name {
+			case "host-fqdn", "hostname":
+				if hostnames[ip] == "" {
+					hostnames[ip] = strings.TrimSpace(prop.Value)
+				}
+			case "operating-system":
+				// Nessus lists alternatives one per line, most likely first
+				oses[ip] = strings.TrimSpace(strings.Split(prop.Value, "\n")[0])
+			}
+		}
+
+		b.host(ip)
+		for _, item := range rh.Items {
+			protocol := strings.ToLower(item.Protocol)
+			if item.Port != 0 {
+				b.addPort(ip, item.Port, protocol, strings.TrimSuffix(item.SvcName, "?"))
+			}
+			if item.Severity <= 0 || item.Severity >= len(nessusSeverities) {
+				continue
+			}
+
+			synopsis := item.Synopsis
+			if synopsis == "" {
+				synopsis = item.Description
+			}
+			b.addFinding(ip, item.Port, protocol, ScanScriptResult{
+				Name:     item.PluginName + " (" + item.PluginID + ")",
+				Output:   formatFindingOutput(synopsis, item.PluginOutput, item.CVEs),
+				Severity: nessusSeverities[item.Severity],
+				Source:   "nessus",
+			})
+		}
+	}
+
+	scan := b.scan()
+	for i := range scan.Hosts {
+		scan.Hosts[i].Hostname = hostnames[scan.Hosts[i].IP]
+		scan.Hosts[i].OS = oses[scan.Hosts[i].IP]
+	}
+	return scan, nil
+}
+
+// OpenVAS / GVM report structs. Exported reports nest <report> elements, so
+// results and hosts are picked out of the token stream wherever they occur.
+type openvasResult struct {
+	Name string `xml:"name"`
+	Host struct {
+		IP       string `xml:",chardata"`
+		Hostname string `xml:"hostname"`
+	} `xml:"host"`
+	Port string `xml:"port"`
+	NVT  struct {
+		OID  string `xml:"oid,attr"`
+		Name string `xml:"name"`
+		Tags string `xml:"tags"`
+		Refs []struct {
+			Type string `xml:"type,attr"`
+			ID   string `xml:"id,attr"`
+		} `xml:"refs>ref"`
+	} `xml:"nvt"`
+	Threat      string `xml:"threat"`
+	Severity    string `xml:"severity"`
+	Description string `xml:"description"`
+}
+
+type openvasHost struct {
+	IP      string `xml:"ip"`
+	Details []struct {
+		Name  string `xml:"name"`
+		Value string `xml:"value"`
+	} `xml:"detail"`
+}
+
+// ParseOpenVAS parses an OpenVAS / GVM XML report. Result ports such as
+// "443/tcp" are marked open; "general/tcp" results are host level. Severity
+// comes from the result's CVSS score, falling back to its threat level.
+func ParseOpenVAS(data []byte) (Scan, error) {
+	b := newReportBuilder()
+	hostnames := make(map[string]string)
+	oses := make(map[string]string)
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Scan{}, errors.New("invalid OpenVAS report: " + err.Error())
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "result":
+			var r openvasResult
+			if err := decoder.DecodeElement(&r, &start); err != nil {
+				return Scan{}, errors.New("invalid OpenVAS result: " + err.Error())
+			}
+			ip := strings.TrimSpace(r.Host.IP)
+			if ip == "" {
+				continue
+			}
+			if r.Host.Hostname != "" && hostnames[ip] == "" {
+				hostnames[ip] = strings.TrimSpace(r.Host.Hostname)
+			}
+
+			var number uint16
+			protocol := "tcp"
+			if portStr, proto, found := strings.Cut(strings.TrimSpace(r.Port), "/"); found {
+				protocol = proto
+				if n, err := strconv.ParseUint(portStr, 10, 16); err == nil {
+					number = uint16(n)
+				}
+			}
+			if number != 0 {
+				b.addPort(ip, number, protocol, "")
+			} else {
+				b.host(ip)
+			}
+
+			severity := openvasSeverity(r.Severity, r.Threat)
+			if severity == SeverityInfo {
+				continue
+			}
+
+			name := r.NVT.Name
+			if name == "" {
+				name = r.Name
+			}
+			var cves []string
+			for _, ref := range r.NVT.Refs {
+				if strings.EqualFold(ref.Type, "cve") {
+					cves = append(cves, ref.ID)
+				}
+			}
+			b.addFinding(ip, number, protocol, ScanScriptResult{
+				Name:     name + " (" + r.NVT.OID + ")",
+				Output:   formatFindingOutput(openvasSummary(r.NVT.Tags), r.Description, cves),
+				Severity: severity,
+				Source:   "openvas",
+			})
+
+		case "host":
+			var h openvasHost
+			if err := decoder.DecodeElement(&h, &start); err != nil {
+				return Scan{}, errors.New("invalid OpenVAS host: " + err.Error())
+			}
+			ip := strings.TrimSpace(h.IP)
+			if ip == "" {
+				continue
+			}
+			for _, d := range h.Details {
+				switch d.Name {
+				case "best_os_txt":
+					oses[ip] = d.Value
+				case "hostname":
+					if hostnames[ip] == "" {
+						hostnames[ip] = d.Value
+					}
+				}
+			}
+		}
+	}
+
+	scan := b.scan()
+	for i := range scan.Hosts {
+		scan.Hosts[i].Hostname = hostnames[scan.Hosts[i].IP]
+		scan.Hosts[i].OS = oses[scan.Hosts[i].IP]
+	}
+	return scan, nil
+}
+
+func openvasSeverity(score string, threat string) string {
+	if s, err := strconv.ParseFloat(strings.TrimSpace(score), 64); err == nil {
+		return SeverityFromCVSS(s)
+	}
+	switch strings.ToLower(threat) {
+	case "high":
+		return SeverityHigh
+	case "medium":
+		return SeverityMedium
+	case "low":
+		return SeverityLow
+	}
+	return SeverityInfo
+}
+
+// openvasSummary pulls the summary out of an NVT tag string such as
+// "cvss_base_vector=...|summary=...|solution=..."
+func openvasSummary(tags string) string {
+	for _, tag := range strings.Split(tags, "|") {
+		if v, found := strings.CutPrefix(tag, "summary="); found {
+			return v
+		}
+	}
+	return ""
+}
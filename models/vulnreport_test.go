@@ -0,0 +1,260 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// nessusSample is trimmed from a Nessus 10 "Basic Network Scan" export
+const nessusSample = `<?xml version="1.0" ?>
+<NessusClientData_v2>
+<Policy><policyName>Basic Network Scan</policyName></Policy>
+<Report name="team1" xmlns:cm="http://www.nessus.org/cm">
+<ReportHost name="10.0.1.5"><HostProperties>
+<tag name="HOST_END">Wed May 29 16:40:12 2024</tag>
+<tag name="operating-system">Linux Kernel 5.15 on Ubuntu 22.04
+Linux Kernel 5.4 on Ubuntu 20.04</tag>
+<tag name="host-ip">10.0.1.5</tag>
+<tag name="host-fqdn">web01.team1.lan</tag>
+<tag name="HOST_START">Wed May 29 16:26:40 2024</tag>
+</HostProperties>
+<ReportItem port="0" svc_name="general" protocol="tcp" severity="0" pluginID="19506" pluginName="Nessus Scan Information" pluginFamily="Settings">
+<synopsis>This plugin displays information about the Nessus scan.</synopsis>
+<plugin_output>Nessus version : 10.7.2</plugin_output>
+</ReportItem>
+<ReportItem port="22" svc_name="ssh" protocol="tcp" severity="0" pluginID="10267" pluginName="SSH Server Type and Version Information" pluginFamily="Service detection">
+<synopsis>An SSH server is listening on this port.</synopsis>
+<plugin_output>SSH version : SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6</plugin_output>
+</ReportItem>
+<ReportItem port="443" svc_name="www" protocol="tcp" severity="2" pluginID="51192" pluginName="SSL Certificate Cannot Be Trusted" pluginFamily="General">
+<description>The server&apos;s X.509 certificate cannot be trusted.</description>
+<plugin_output>The following certificate was at the top of the certificate chain :
+|-Subject : CN=web01</plugin_output>
+<risk_factor>Medium</risk_factor>
+</ReportItem>
+<ReportItem port="0" svc_name="general" protocol="tcp" severity="4" pluginID="201194" pluginName="Canonical Ubuntu Linux SEoL (22.04.x)" pluginFamily="General">
+<synopsis>An unsupported version of Canonical Ubuntu Linux is installed on the remote host.</synopsis>
+<cve>CVE-2024-0001</cve>
+<cve>CVE-2024-0002</cve>
+</ReportItem>
+</ReportHost>
+<ReportHost name="10.0.1.6"><HostProperties>
+<tag name="host-ip">10.0.1.6</tag>
+</HostProperties>
+<ReportItem port="0" svc_name="general" protocol="udp" severity="3" pluginID="10114" pluginName="ICMP Timestamp Request Remote Date Disclosure" pluginFamily="General">
+<synopsis>It is possible to determine the exact time set on the remote host.</synopsis>
+</ReportItem>
+</ReportHost>
+</Report>
+</NessusClientData_v2>
+`
+
+// openvasSample is trimmed from a GVM 22.4 XML report export, which nests a
+// report element inside another
+const openvasSample = `<report id="4f1f0c68" format_id="a994b278" extension="xml" content_type="text/xml">
+<owner><name>admin</name></owner>
+<name>2024-05-29T16:26:40Z</name>
+<report id="4f1f0c68">
+<scan_run_status>Done</scan_run_status>
+<results start="1" max="100">
+<result id="1a2b">
+<name>SSH Weak Encryption Algorithms Supported</name>
+<host>10.0.1.5<asset asset_id="a1"/><hostname>web01.team1.lan</hostname></host>
+<port>22/tcp</port>
+<nvt oid="1.3.6.1.4.1.25623.1.0.105611">
+<type>nvt</type>
+<name>Weak Encryption Algorithm(s) Supported (SSH)</name>
+<cvss_base>4.3</cvss_base>
+<tags>cvss_base_vector=AV:N/AC:M/Au:N/C:P/I:N/A:N|summary=The remote SSH server is configured to allow weak encryption algorithms.|insight=...|solution_type=Mitigation</tags>
+<refs><ref type="url" id="https://tools.ietf.org/html/rfc4253"/></refs>
+</nvt>
+<threat>Medium</threat>
+<severity>4.3</severity>
+<description>The following weak client-to-server encryption algorithms are supported by the remote service:
+
+arcfour</description>
+</result>
+<result id="3c4d">
+<name>OS Detection Consolidation and Reporting</name>
+<host>10.0.1.5<asset asset_id="a1"/><hostname>web01.team1.lan</hostname></host>
+<port>general/tcp</port>
+<nvt oid="1.3.6.1.4.1.25623.1.0.105937"><type>nvt</type><name>OS Detection Consolidation and Reporting</name><tags>summary=Reports the detected OS.</tags></nvt>
+<threat>Log</threat>
+<severity>0.0</severity>
+<description>Best matching OS: Ubuntu 22.04</description>
+</result>
+<result id="5e6f">
+<name>OpenSSL Vulnerability</name>
+<host>10.0.1.5<asset asset_id="a1"/></host>
+<port>general/tcp</port>
+<nvt oid="1.3.6.1.4.1.25623.1.0.1000">
+<type>nvt</type>
+<name>OpenSSL Remote Code Execution</name>
+<tags>summary=OpenSSL is prone to a remote code execution vulnerability.</tags>
+<refs><ref type="cve" id="CVE-2024-1111"/><ref type="cert-bund" id="WID-SEC-2024-0001"/></refs>
+</nvt>
+<threat>High</threat>
+<severity>9.8</severity>
+<description></description>
+</result>
+<result id="7a8b">
+<name>HTTP Server Banner</name>
+<host>10.0.1.7<asset asset_id="a3"/></host>
+<port>80/tcp</port>
+<nvt oid="1.3.6.1.4.1.25623.1.0.10107"><type>nvt</type><name>HTTP Server type and version</name></nvt>
+<threat>Low</threat>
+<severity></severity>
+<description>The remote HTTP Server banner is: Apache</description>
+</result>
+</results>
+<host><ip>10.0.1.5</ip><start>2024-05-29T16:26:45Z</start>
+<detail><name>best_os_txt</name><value>Ubuntu 22.04</value></detail>
+<detail><name>hostname</name><value>web01</value></detail>
+</host>
+<host><ip>10.0.1.7</ip>
+<detail><name>hostname</name><value>files.team1.lan</value></detail>
+</host>
+</report>
+</report>
+`
+
+func TestVulnReportParsers(t *testing.T) {
+	tests := []struct {
+		name    string
+		parse   func([]byte) (Scan, error)
+		data    string
+		want    []ScanHost
+		wantErr string
+	}{
+		{
+			name:  "nessus",
+			parse: ParseNessus,
+			data:  nessusSample,
+			want: []ScanHost{
+				{
+					IP: "10.0.1.5", Hostname: "web01.team1.lan", OS: "Linux Kernel 5.15 on Ubuntu 22.04", Status: "up",
+					Ports: []ScanPort{
+						{Number: 22, State: "open", Protocol: "tcp", Service: "ssh"},
+						{Number: 443, State: "open", Protocol: "tcp", Service: "www", Scripts: []ScanScriptResult{{
+							Name:     "SSL Certificate Cannot Be Trusted (51192)",
+							Output:   "The server's X.509 certificate cannot be trusted.\n\nThe following certificate was at the top of the certificate chain :\n|-Subject : CN=web01",
+							Severity: SeverityMedium,
+							Source:   "nessus",
+						}}},
+					},
+					Scripts: []ScanScriptResult{{
+						Name:     "Canonical Ubuntu Linux SEoL (22.04.x) (201194)",
+						Output:   "An unsupported version of Canonical Ubuntu Linux is installed on the remote host.\n\nCVE: CVE-2024-0001, CVE-2024-0002",
+						Severity: SeverityCritical,
+						Source:   "nessus",
+					}},
+				},
+				{
+					// A host-level finding does not make up a port
+					IP: "10.0.1.6", Status: "up", Ports: []ScanPort{},
+					Scripts: []ScanScriptResult{{
+						Name:     "ICMP Timestamp Request Remote Date Disclosure (10114)",
+						Output:   "It is possible to determine the exact time set on the remote host.",
+						Severity: SeverityHigh,
+						Source:   "nessus",
+					}},
+				},
+			},
+		},
+		{
+			name:  "nessus without hosts",
+			parse: ParseNessus,
+			data:  `<NessusClientData_v2><Report name="empty"></Report></NessusClientData_v2>`,
+			want:  []ScanHost{},
+		},
+		{
+			name:    "nessus wrong document",
+			parse:   ParseNessus,
+			data:    openvasSample,
+			wantErr: "invalid Nessus report",
+		},
+		{
+			name:  "openvas",
+			parse: ParseOpenVAS,
+			data:  openvasSample,
+			want: []ScanHost{
+				{
+					IP: "10.0.1.5", Hostname: "web01.team1.lan", OS: "Ubuntu 22.04", Status: "up",
+					Ports: []ScanPort{{Number: 22, State: "open", Protocol: "tcp", Scripts: []ScanScriptResult{
+						{
+							Name:     "Weak Encryption Algorithm(s) Supported (SSH) (1.3.6.1.4.1.25623.1.0.105611)",
+							Output:   "The remote SSH server is configured to allow weak encryption algorithms.\n\nThe following weak client-to-server encryption algorithms are supported by the remote service:\n\narcfour",
+							Severity: SeverityMedium,
+							Source:   "openvas",
+						},
+					}}},
+					Scripts: []ScanScriptResult{{
+						Name:     "OpenSSL Remote Code Execution (1.3.6.1.4.1.25623.1.0.1000)",
+						Output:   "OpenSSL is prone to a remote code execution vulnerability.\n\nCVE: CVE-2024-1111",
+						Severity: SeverityCritical,
+						Source:   "openvas",
+					}},
+				},
+				{
+					IP: "10.0.1.7", Hostname: "files.team1.lan", Status: "up",
+					Ports: []ScanPort{{Number: 80, State: "open", Protocol: "tcp", Scripts: []ScanScriptResult{{
+						Name:     "HTTP Server type and version (1.3.6.1.4.1.25623.1.0.10107)",
+						Output:   "The remote HTTP Server banner is: Apache",
+						Severity: SeverityLow,
+						Source:   "openvas",
+					}}}},
+				},
+			},
+		},
+		{
+			name:    "openvas truncated",
+			parse:   ParseOpenVAS,
+			data:    openvasSample[:700],
+			wantErr: "invalid OpenVAS",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scan, err := tt.parse([]byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !scan.PortsOnly {
+				t.Error("vulnerability reports should be ports only")
+			}
+			if !reflect.DeepEqual(scan.Hosts, tt.want) {
+				t.Errorf("hosts = %+v\nwant %+v", scan.Hosts, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeverityFromCVSS(t *testing.T) {
+	tests := []struct {
+		score float64
+		want  string
+	}{
+		{10.0, SeverityCritical},
+		{9.0, SeverityCritical},
+		{8.9, SeverityHigh},
+		{7.0, SeverityHigh},
+		{6.9, SeverityMedium},
+		{4.0, SeverityMedium},
+		{3.9, SeverityLow},
+		{0.1, SeverityLow},
+		{0, SeverityInfo},
+	}
+	for _, tt := range tests {
+		if got := SeverityFromCVSS(tt.score); got != tt.want {
+			t.Errorf("SeverityFromCVSS(%v) = %q, want %q", tt.score, got, tt.want)
+		}
+	}
+}
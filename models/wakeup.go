@@ -0,0 +1,27 @@
+package models
+
+import "sync"
+
+// workSignal wakes scanners long-polling for a job. The channel is closed
+// and replaced whenever new work may be available, waking every waiter.
+var workSignal = struct {
+	sync.Mutex
+	ch chan struct{}
+}{ch: make(chan struct{})}
+
+// WorkChanged returns a channel that is closed the next time NotifyWork is
+// called. Take it before looking for work so a wakeup is never missed.
+func WorkChanged() <-chan struct{} {
+	workSignal.Lock()
+	defer workSignal.Unlock()
+	return workSignal.ch
+}
+
+// NotifyWork wakes every scanner waiting for a job, such as after a team is
+// added, a job is queued or job handout is resumed
+func NotifyWork() {
+	workSignal.Lock()
+	defer workSignal.Unlock()
+	close(workSignal.ch)
+	workSignal.ch = make(chan struct{})
+}
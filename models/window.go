@@ -0,0 +1,145 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Scan window kinds
+const (
+	WindowAllow    = "allow"    // scanning only happens inside allow windows, if any exist
+	WindowBlackout = "blackout" // no scanning inside a blackout window
+)
+
+// blockedRetry is how long scanners are told to wait when it is not known
+// when scanning will resume
+const blockedRetry = time.Minute
+
+// ScanWindow is a period from the rules of engagement during which scanning
+// is allowed or forbidden. A window without a team applies to every team.
+type ScanWindow struct {
+	gorm.Model `json:"-"`
+	WID        string    `json:"wid" gorm:"uniqueIndex;column:w_id"`
+	TeamID     string    `json:"team_id" gorm:"index"` // empty for global windows
+	Kind       string    `json:"kind"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	Reason     string    `json:"reason"`
+}
+
+// ScanWindowRequest for creating/updating scan windows via API
+type ScanWindowRequest struct {
+	TeamID string    `json:"team_id"`
+	Kind   string    `json:"kind" binding:"required"`
+	Start  time.Time `json:"start" binding:"required"`
+	End    time.Time `json:"end" binding:"required"`
+	Reason string    `json:"reason"`
+}
+
+func MakeScanWindow(teamID string, kind string, start time.Time, end time.Time) ScanWindow {
+	var w ScanWindow
+	w.WID = uuid.New().String()
+	w.TeamID = teamID
+	w.Kind = kind
+	w.Start = start
+	w.End = end
+	return w
+}
+
+// Validate checks the window's kind and times
+func (w ScanWindow) Validate() error {
+	if w.Kind != WindowAllow && w.Kind != WindowBlackout {
+		return errors.New("kind must be allow or blackout")
+	}
+	if !w.End.After(w.Start) {
+		return errors.New("end must be after start")
+	}
+	return nil
+}
+
+// Active reports whether t falls inside the window
+func (w ScanWindow) Active(t time.Time) bool {
+	return !t.Before(w.Start) && t.Before(w.End)
+}
+
+// windowBlock reports whether a set of windows forbids scanning at now and,
+// if so, when that may change (zero when no later window lifts the block)
+func windowBlock(windows []ScanWindow, now time.Time) (bool, time.Time) {
+	blocked := false
+	var until time.Time
+	hasAllow, inAllow := false, false
+	var nextAllow time.Time
+
+	for _, w := range windows {
+		switch w.Kind {
+		case WindowBlackout:
+			if w.Active(now) {
+				blocked = true
+				if w.End.After(until) {
+					until = w.End
+				}
+			}
+		case WindowAllow:
+			hasAllow = true
+			if w.Active(now) {
+				inAllow = true
+			} else if w.Start.After(now) && (nextAllow.IsZero() || w.Start.Before(nextAllow)) {
+				nextAllow = w.Start
+			}
+		}
+	}
+
+	if hasAllow && !inAllow {
+		if nextAllow.IsZero() {
+			return true, time.Time{}
+		}
+		if !blocked || nextAllow.After(until) {
+			until = nextAllow
+		}
+		blocked = true
+	}
+	return blocked, until
+}
+
+// WindowBlocks returns the teams that may not be scanned at now because of
+// scan windows, with when each block may lift (zero if unknown)
+func WindowBlocks(tx *gorm.DB, teams []Team, now time.Time) (map[string]time.Time, error) {
+	// Past windows still count: an allow list that has run out blocks scanning
+	var windows []ScanWindow
+	if err := tx.Find(&windows).Error; err != nil {
+		return nil, err
+	}
+
+	global := []ScanWindow{}
+	byTeam := make(map[string][]ScanWindow)
+	for _, w := range windows {
+		if w.TeamID == "" {
+			global = append(global, w)
+		} else {
+			byTeam[w.TeamID] = append(byTeam[w.TeamID], w)
+		}
+	}
+
+	globalBlocked, globalUntil := windowBlock(global, now)
+	blocks := make(map[string]time.Time)
+	for _, team := range teams {
+		teamBlocked, teamUntil := windowBlock(byTeam[team.TID], now)
+		if !globalBlocked && !teamBlocked {
+			continue
+		}
+		// Blocked until both the global and the team block lift
+		until := globalUntil
+		if !globalBlocked {
+			until = teamUntil
+		} else if teamBlocked && (teamUntil.IsZero() || until.IsZero()) {
+			until = time.Time{}
+		} else if teamBlocked && teamUntil.After(until) {
+			until = teamUntil
+		}
+		blocks[team.TID] = until
+	}
+	return blocks, nil
+}
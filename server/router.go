@@ -84,21 +84,89 @@ func NewRouter() *gin.Engine {
 	router.PUT("/teams/:tid", middleware.Authorize("admin"), team.UpdateTeam)
 	router.DELETE("/teams/:tid", middleware.Authorize("admin"), team.DeleteTeam)
 
+	// Baseline endpoints
+	baseline := new(controllers.BaselineController)
+	router.GET("/teams/:tid/baselines", middleware.Authorize("viewer"), baseline.GetBaselines)
+	router.POST("/teams/:tid/baselines", middleware.Authorize("admin"), baseline.CreateBaseline)
+	router.POST("/teams/:tid/baselines/snapshot", middleware.Authorize("admin"), baseline.SnapshotBaseline)
+	router.POST("/teams/:tid/baselines/propagate", middleware.Authorize("admin"), baseline.PropagateBaseline)
+	router.PUT("/baselines/:bid", middleware.Authorize("admin"), baseline.UpdateBaseline)
+	router.DELETE("/baselines/:bid", middleware.Authorize("admin"), baseline.DeleteBaseline)
+
+	// Report import endpoints
+	imports := new(controllers.ImportController)
+	router.POST("/teams/:tid/import", middleware.Authorize("admin"), imports.ImportReport)
+
 	// Job endpoints
 	jobs := new(controllers.JobController)
 	router.GET("/jobs/manager", middleware.Authorize("viewer"), jobs.GetJobManagerState)
+	router.GET("/jobs/stats", middleware.Authorize("viewer"), jobs.GetJobStats)
+	router.GET("/jobs/progress", middleware.Authorize("viewer"), jobs.GetProgress)
 	router.GET("/jobs/:jobtype/next", middleware.Authorize("scanner"), jobs.NewJob)
 	router.GET("/jobs", middleware.Authorize("any"), jobs.GetJobs)
+	router.POST("/jobs", middleware.Authorize("admin"), jobs.CreateAdHocJob)
 	router.POST("/jobs/nmap/:jid", middleware.Authorize("scanner"), jobs.UploadScan)
 	router.POST("/jobs/:jid/cancel", middleware.Authorize("admin"), jobs.CancelJob)
+	router.POST("/jobs/:jid/heartbeat", middleware.Authorize("scanner"), jobs.Heartbeat)
+	router.POST("/jobs/:jid/progress", middleware.Authorize("scanner"), jobs.ReportProgress)
+	router.POST("/jobs/:jid/fail", middleware.Authorize("scanner"), jobs.FailJob)
+
+	// Job artifact endpoints
+	artifacts := new(controllers.ArtifactController)
+	router.POST("/jobs/:jid/artifacts", middleware.Authorize("scanner"), artifacts.UploadArtifacts)
+	router.GET("/artifacts", middleware.Authorize("viewer"), artifacts.GetArtifacts)
+	router.GET("/artifacts/:aid", middleware.Authorize("viewer"), artifacts.DownloadArtifact)
+	router.POST("/artifacts/:aid/reingest", middleware.Authorize("admin"), artifacts.ReingestArtifact)
+
+	// Scanner agent endpoints
+	scanners := new(controllers.ScannerController)
+	router.GET("/scanners", middleware.Authorize("viewer"), scanners.GetScanners)
+	router.POST("/scanners/register", middleware.Authorize("scanner"), scanners.RegisterScanner)
+	router.POST("/scanners/heartbeat", middleware.Authorize("scanner"), scanners.ScannerHeartbeat)
+	router.PUT("/scanners/:name/tags", middleware.Authorize("admin"), scanners.SetScannerTags)
+	router.DELETE("/scanners/:name", middleware.Authorize("admin"), scanners.DeleteScanner)
+
+	// Job type endpoints
+	jobtypes := new(controllers.JobTypeController)
+	router.POST("/jobtypes", middleware.Authorize("admin"), jobtypes.CreateJobType)
+	router.PUT("/jobtypes/:name", middleware.Authorize("admin"), jobtypes.UpdateJobType)
+	router.DELETE("/jobtypes/:name", middleware.Authorize("admin"), jobtypes.DeleteJobType)
+
+	// Schedule endpoints
+	schedules := new(controllers.ScheduleController)
+	router.GET("/schedules", middleware.Authorize("viewer"), schedules.GetSchedules)
+	router.POST("/schedules", middleware.Authorize("admin"), schedules.CreateSchedule)
+	router.PUT("/schedules/:sid", middleware.Authorize("admin"), schedules.UpdateSchedule)
+	router.DELETE("/schedules/:sid", middleware.Authorize("admin"), schedules.DeleteSchedule)
+
+	// Exclusion endpoints
+	exclusions := new(controllers.ExclusionController)
+	router.GET("/exclusions", middleware.Authorize("viewer"), exclusions.GetExclusions)
+	router.POST("/exclusions", middleware.Authorize("admin"), exclusions.CreateExclusion)
+	router.DELETE("/exclusions/:eid", middleware.Authorize("admin"), exclusions.DeleteExclusion)
+
+	// Scan window and queue pause endpoints
+	windows := new(controllers.WindowController)
+	router.GET("/windows", middleware.Authorize("viewer"), windows.GetWindows)
+	router.POST("/windows", middleware.Authorize("admin"), windows.CreateWindow)
+	router.PUT("/windows/:wid", middleware.Authorize("admin"), windows.UpdateWindow)
+	router.DELETE("/windows/:wid", middleware.Authorize("admin"), windows.DeleteWindow)
+	router.GET("/queue", middleware.Authorize("viewer"), windows.GetQueueState)
+	router.POST("/queue/pause", middleware.Authorize("admin"), windows.PauseQueue)
+	router.POST("/queue/resume", middleware.Authorize("admin"), windows.ResumeQueue)
 
 	// Host endpoints
 	host := new(controllers.HostController)
 	router.GET("/hosts/by-team/:tid", middleware.Authorize("viewer"), host.GetHostsByTeam)
 	router.GET("/hosts/by-team/", middleware.Authorize("viewer"), host.GetAllHostsByTeam)
+	router.GET("/hosts/by-team/:tid/events", middleware.Authorize("viewer"), host.GetPortEvents)
 	router.GET("/dashboard/data", middleware.Authorize("viewer"), host.GetDashboardData)
 	router.GET("/vulnerabilities", middleware.Authorize("viewer"), host.GetVulnerabilities)
 
+	// Event stream
+	events := new(controllers.EventController)
+	router.GET("/events", middleware.Authorize("viewer"), events.StreamEvents)
+
 	// Swagger
 	docs.SwaggerInfo.BasePath = "/"
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
@@ -158,6 +226,15 @@ func NewRouter() *gin.Engine {
 		})
 	})
 
+	router.GET("/scanners.html", middleware.AuthorizeHTML("admin"), func(c *gin.Context) {
+		session := sessions.Default(c)
+		c.HTML(http.StatusOK, "scanners.html", gin.H{
+			"user":  session.Get("user"),
+			"roles": session.Get("roles"),
+			"title": "Scanners",
+		})
+	})
+
 	router.GET("/vulns.html", middleware.AuthorizeHTML("viewer"), func(c *gin.Context) {
 		session := sessions.Default(c)
 		c.HTML(http.StatusOK, "vulns.html", gin.H{
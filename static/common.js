@@ -1,6 +1,6 @@
 // API Helper Functions
 const API = {
-    async request(url, options = {}) {
+    async send(url, options = {}) {
         const defaultOptions = {
             headers: {
                 'Content-Type': 'application/json',
@@ -22,13 +22,28 @@ const API = {
             throw new Error(data.message || 'Request failed');
         }
 
-        return data;
+        return { data, response };
+    },
+
+    async request(url, options = {}) {
+        return (await this.send(url, options)).data;
     },
 
     get(url) {
         return this.request(url);
     },
 
+    // Fetch one page of a paginated list, with the total from X-Total-Count
+    // and the cursor for the next page from X-Next-Cursor
+    async getPage(url) {
+        const { data, response } = await this.send(url);
+        return {
+            items: data,
+            total: parseInt(response.headers.get('X-Total-Count') || data.length, 10),
+            next: response.headers.get('X-Next-Cursor') || '',
+        };
+    },
+
     post(url, body) {
         return this.request(url, {
             method: 'POST',
@@ -50,6 +65,28 @@ const API = {
     },
 };
 
+// Live updates from the /events stream. Handlers are called with the
+// event's data and the whole event. The browser reconnects on its own and
+// is sent what it missed; if that is no longer kept a 'reset' event fires
+// instead and the page should reload its data.
+const Events = {
+    source: null,
+
+    connect() {
+        if (!this.source) {
+            this.source = new EventSource('/events');
+        }
+        return this.source;
+    },
+
+    on(type, handler) {
+        this.connect().addEventListener(type, (e) => {
+            const event = JSON.parse(e.data);
+            handler(event.data, event);
+        });
+    },
+};
+
 // Toast Notifications
 const Toast = {
     container: null,